@@ -0,0 +1,135 @@
+package router
+
+import (
+	"time"
+)
+
+// delivery is an event returned by NextAck waiting for acknowledgement
+type delivery struct {
+	event    *Event
+	deadline time.Time
+}
+
+// track stores the event until it is acknowledged and returns the function acknowledging it
+func (w *tableWatcher) track(e *Event) func() {
+	w.Lock()
+	defer w.Unlock()
+
+	w.deliveries++
+	key := w.deliveries
+	w.inflight[key] = &delivery{
+		event:    e,
+		deadline: time.Now().Add(w.opts.VisibilityTimeout),
+	}
+
+	return func() {
+		w.Lock()
+		delete(w.inflight, key)
+		w.Unlock()
+	}
+}
+
+// expired returns the event whose visibility timeout has expired first, extending its
+// deadline, and the function acknowledging it. Otherwise it returns the time until the
+// first deadline, or zero if no event is waiting for acknowledgement.
+func (w *tableWatcher) expired() (*Event, func(), time.Duration) {
+	w.Lock()
+	defer w.Unlock()
+
+	var first *delivery
+	var key uint64
+
+	for k, d := range w.inflight {
+		if first == nil || d.deadline.Before(first.deadline) {
+			first, key = d, k
+		}
+	}
+
+	if first == nil {
+		return nil, nil, 0
+	}
+
+	now := time.Now()
+	if wait := first.deadline.Sub(now); wait > 0 {
+		return nil, nil, wait
+	}
+
+	first.deadline = now.Add(w.opts.VisibilityTimeout)
+
+	return first.event, func() {
+		w.Lock()
+		delete(w.inflight, key)
+		w.Unlock()
+	}, 0
+}
+
+// NextAck returns the next event along with the function acknowledging it. Unless the event
+// is acknowledged within the watcher visibility timeout, see WatchVisibilityTimeout, it is
+// returned again by NextAck, before any new event, until it is acknowledged. Acknowledging
+// an event more than once has no effect.
+//
+// The redelivered events are older than the events returned since they have been delivered
+// first, so the events of a route may be received out of order. The consumers should discard
+// the redelivered events of routes they have received newer events of, e.g. by comparing the
+// event sequence numbers. Without the visibility timeout and for level triggered watchers,
+// which always deliver the latest state of the routes, NextAck returns the next event and
+// the events are never redelivered.
+func (w *tableWatcher) NextAck() (*Event, func(), error) {
+	if w.opts.VisibilityTimeout <= 0 || w.opts.LevelTriggered {
+		e, err := w.Next()
+		return e, func() {}, err
+	}
+
+	for {
+		e, ack, wait := w.expired()
+		if e != nil {
+			return e, ack, nil
+		}
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+
+		res, ok, err := w.receive(timeout)
+		if timer != nil {
+			timer.Stop()
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			return res, w.track(res), nil
+		}
+	}
+}
+
+// receive returns the next queued event matching the watch filters. It returns false
+// if the timeout has fired first and ErrWatcherStopped if the watcher has been stopped.
+func (w *tableWatcher) receive(timeout <-chan time.Time) (*Event, bool, error) {
+	for {
+		select {
+		case res, ok := <-w.resChan:
+			// the channel is closed when the table is closed
+			if !ok {
+				return nil, false, ErrWatcherStopped
+			}
+			if !w.filter(res) {
+				w.stats.incFiltered()
+				// acknowledge the events filtered out after they have been delivered
+				if w.acks != nil {
+					w.acks <- struct{}{}
+				}
+				continue
+			}
+			w.advance(res)
+			return res, true, nil
+		case <-timeout:
+			return nil, false, nil
+		case <-w.done:
+			return nil, false, ErrWatcherStopped
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package router
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestNextAck(t *testing.T) {
+	table := newTable(DispatchWorkers(1))
+	defer table.Close()
+
+	w, err := table.Watch(WatchVisibilityTimeout(100 * time.Millisecond))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	_, route := testSetup()
+
+	for i := 1; i <= 2; i++ {
+		route.Address = fmt.Sprintf("10.0.0.%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	aw := w.(AckWatcher)
+
+	// the first event is acked, the second one is not
+	first, ack, err := aw.NextAck()
+	if err != nil {
+		t.Fatalf("error receiving event: %s", err)
+	}
+	ack()
+	ack()
+
+	second, _, err := aw.NextAck()
+	if err != nil {
+		t.Fatalf("error receiving event: %s", err)
+	}
+	if first.Seq != 1 || second.Seq != 2 {
+		t.Fatalf("expected events 1 and 2, found: %d and %d", first.Seq, second.Seq)
+	}
+
+	start := time.Now()
+	redelivered, ack, err := aw.NextAck()
+	if err != nil {
+		t.Fatalf("error receiving event: %s", err)
+	}
+	if redelivered.Seq != second.Seq {
+		t.Errorf("expected redelivered event %d, found: %d", second.Seq, redelivered.Seq)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected redelivery after the visibility timeout, found: %s", elapsed)
+	}
+	ack()
+
+	// nothing is redelivered once all the events are acked
+	done := make(chan *Event)
+	go func() {
+		e, _, _ := aw.NextAck()
+		done <- e
+	}()
+
+	select {
+	case e := <-done:
+		t.Errorf("unexpected redelivery: %v", e)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	w.Stop()
+	<-done
+}
@@ -0,0 +1,43 @@
+package router
+
+// AddAlias adds an alias for the destination service so that lookups
+// and watchers of the alias resolve to the routes of the destination
+func (t *MemoryTable) AddAlias(alias, service string) {
+	t.amu.Lock()
+	defer t.amu.Unlock()
+
+	t.aliases[alias] = service
+}
+
+// RemoveAlias removes the alias. The routes of the destination service are not affected.
+func (t *MemoryTable) RemoveAlias(alias string) {
+	t.amu.Lock()
+	defer t.amu.Unlock()
+
+	delete(t.aliases, alias)
+}
+
+// Aliases returns a copy of all the aliases mapped to their destination service
+func (t *MemoryTable) Aliases() map[string]string {
+	t.amu.RLock()
+	defer t.amu.RUnlock()
+
+	aliases := make(map[string]string, len(t.aliases))
+	for alias, service := range t.aliases {
+		aliases[alias] = service
+	}
+
+	return aliases
+}
+
+// resolve returns the destination service of the alias or the service itself if it's not an alias
+func (t *MemoryTable) resolve(service string) string {
+	t.amu.RLock()
+	defer t.amu.RUnlock()
+
+	if dest, ok := t.aliases[service]; ok {
+		return dest
+	}
+
+	return service
+}
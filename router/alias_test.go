@@ -0,0 +1,66 @@
+package router
+
+import (
+	"testing"
+)
+
+func TestAlias(t *testing.T) {
+	table, route := testSetup()
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	table.AddAlias("legacy.svc", route.Service)
+
+	if aliases := table.Aliases(); aliases["legacy.svc"] != route.Service {
+		t.Errorf("alias not listed: %v", aliases)
+	}
+
+	// lookup through the alias
+	routes, err := table.Query(QueryService("legacy.svc"))
+	if err != nil {
+		t.Fatalf("error looking up routes: %s", err)
+	}
+
+	if len(routes) != 1 || routes[0].Service != route.Service {
+		t.Errorf("incorrect routes returned for alias: %+v", routes)
+	}
+
+	// watching the alias receives events for the destination
+	w, err := table.Watch(WatchService("legacy.svc"))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	route.Address = "dest.addr2"
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	event, err := w.Next()
+	if err != nil {
+		t.Fatalf("error receiving event: %s", err)
+	}
+
+	if event.Route.Service != route.Service {
+		t.Errorf("incorrect event route. Expected service: %s, found: %s", route.Service, event.Route.Service)
+	}
+
+	// removing the alias doesn't affect the destination routes
+	table.RemoveAlias("legacy.svc")
+
+	if _, err := table.Query(QueryService("legacy.svc")); err != ErrRouteNotFound {
+		t.Errorf("expected error: %s, found: %v", ErrRouteNotFound, err)
+	}
+
+	routes, err = table.Query(QueryService(route.Service))
+	if err != nil {
+		t.Fatalf("error looking up routes: %s", err)
+	}
+
+	if len(routes) != 2 {
+		t.Errorf("incorrect number of routes returned. Expected: %d, found: %d", 2, len(routes))
+	}
+}
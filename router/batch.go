@@ -0,0 +1,104 @@
+package router
+
+// WatchSpec specifies a watcher created by WatchBatch
+type WatchSpec struct {
+	// Options are the watch options
+	Options []WatchOption
+}
+
+// WatchBatch creates the watchers from a single consistent snapshot of the table.
+// Every watcher first receives the events replaying the snapshot, catching up from
+// its WatchSince routes or from an empty table, and then the live events. All the
+// watchers are registered atomically; if any of them can not be created none is.
+func (t *MemoryTable) WatchBatch(specs []WatchSpec) ([]Watcher, error) {
+	watchers, err := t.watchBatch(specs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Watcher, len(watchers))
+	for i, w := range watchers {
+		// the hook runs outside of the table locks
+		if t.opts.OnWatcherAdded != nil {
+			t.opts.OnWatcherAdded(w.info())
+		}
+		result[i] = w
+	}
+
+	return result, nil
+}
+
+// watchBatch creates and registers the batch of table watchers
+func (t *MemoryTable) watchBatch(specs []WatchSpec) ([]*tableWatcher, error) {
+	opts := make([]WatchOptions, len(specs))
+	criteria := make([]watchCriteria, len(specs))
+
+	for i, spec := range specs {
+		wopts, c, err := watchOptions(spec.Options...)
+		if err != nil {
+			return nil, err
+		}
+		opts[i] = wopts
+		criteria[i] = c
+	}
+
+	// take the snapshot and register the watchers under the
+	// table lock so that no mutation happens in between
+	t.RLock()
+	defer t.RUnlock()
+
+	snapshot := t.list()
+
+	watchers := make([]*tableWatcher, len(specs))
+	catchups := make([][]*Event, len(specs))
+
+	for i, wopts := range opts {
+		since := wopts.Since
+		if since == nil {
+			since = []Route{}
+		}
+		catchups[i] = t.catchup(since, snapshot)
+		watchers[i] = t.newWatcher(wopts, criteria[i], len(catchups[i])+10)
+		watchers[i].from = t.seq
+		watchers[i].position = t.seq
+	}
+
+	t.wmu.Lock()
+	defer t.wmu.Unlock()
+
+	// rollback stops all the watchers, removing the registered ones
+	rollback := func() {
+		for _, w := range watchers {
+			t.removeWatcher(w.id)
+			w.registered = false
+			w.Stop()
+		}
+	}
+
+	select {
+	case <-t.exit:
+		rollback()
+		return nil, ErrTableClosed
+	default:
+	}
+
+	for i, w := range watchers {
+		if err := t.checkWatchers(opts[i]); err != nil {
+			rollback()
+			return nil, err
+		}
+		t.addWatcher(w)
+	}
+
+	for i, w := range watchers {
+		if w.view != nil && opts[i].Since != nil {
+			w.seed(opts[i].Since)
+		}
+		for _, e := range catchups[i] {
+			w.send(e)
+		}
+		t.startResync(w)
+	}
+
+	return watchers, nil
+}
@@ -0,0 +1,68 @@
+package router
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestWatchBatch(t *testing.T) {
+	table := newTable(MaxWatchers(3))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	for i := 0; i < 5; i++ {
+		route.Address = fmt.Sprintf("10.0.0.%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	watchers, err := table.WatchBatch([]WatchSpec{
+		{Options: []WatchOption{WatchService(route.Service)}},
+		{},
+	})
+	if err != nil {
+		t.Fatalf("error creating watchers: %s", err)
+	}
+
+	for _, w := range watchers {
+		defer w.Stop()
+	}
+
+	replay := func(w Watcher) []string {
+		var addresses []string
+		for _, e := range collectEvents(w, 100*time.Millisecond) {
+			if e.Type != Create {
+				t.Errorf("expected create event, found: %s", e)
+			}
+			addresses = append(addresses, e.Route.Address)
+		}
+		sort.Strings(addresses)
+		return addresses
+	}
+
+	first, second := replay(watchers[0]), replay(watchers[1])
+
+	if len(first) != 5 {
+		t.Fatalf("expected 5 replayed events, found: %d", len(first))
+	}
+
+	if fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Errorf("expected identical replays, found: %v and %v", first, second)
+	}
+
+	// the batch is registered atomically
+	if _, err := table.WatchBatch([]WatchSpec{{}, {}}); err != ErrTooManyWatchers {
+		t.Fatalf("expected error: %s, found: %v", ErrTooManyWatchers, err)
+	}
+
+	// the rolled back watchers do not take the watcher slots
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	w.Stop()
+}
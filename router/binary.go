@@ -0,0 +1,192 @@
+package router
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"time"
+)
+
+const (
+	// binaryVersion is the version of the route binary encoding
+	binaryVersion = 3
+)
+
+var (
+	// ErrUnsupportedEncoding is returned when decoding a route encoded by an unknown encoding version
+	ErrUnsupportedEncoding = errors.New("unsupported route encoding version")
+	// ErrInvalidEncoding is returned when decoding a malformed route encoding
+	ErrInvalidEncoding = errors.New("invalid route encoding")
+)
+
+// MarshalBinary encodes the route into a compact binary form.
+// The encoding starts with a version byte followed by the varint encoded fields.
+func (r Route) MarshalBinary() ([]byte, error) {
+	b := []byte{binaryVersion}
+
+	for _, s := range []string{r.Service, r.Address, r.Gateway, r.Network, r.Router, r.Link} {
+		b = appendString(b, s)
+	}
+
+	b = appendVarint(b, r.Metric)
+	b = appendUvarint(b, r.Version)
+	b = appendUvarint(b, uint64(r.Status))
+	b = appendUvarint(b, uint64(r.Class))
+	b = appendVarint(b, int64(r.Weight))
+	b = appendTime(b, r.LastSeen)
+	b = appendTime(b, r.ActivateAt)
+
+	// encode the metadata in key order so the encoding is deterministic
+	keys := make([]string, 0, len(r.Metadata))
+	for k := range r.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	b = appendUvarint(b, uint64(len(keys)))
+	for _, k := range keys {
+		b = appendString(b, k)
+		b = appendString(b, r.Metadata[k])
+	}
+
+	return b, nil
+}
+
+// UnmarshalBinary decodes the route from its binary form.
+// It returns ErrUnsupportedEncoding if the encoding version is unknown.
+func (r *Route) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 {
+		return ErrInvalidEncoding
+	}
+
+	// version 1 encodes no route class and version 2 no route weight
+	version := b[0]
+	if version < 1 || version > binaryVersion {
+		return ErrUnsupportedEncoding
+	}
+
+	d := &decoder{b: b[1:]}
+
+	var route Route
+	for _, s := range []*string{&route.Service, &route.Address, &route.Gateway, &route.Network, &route.Router, &route.Link} {
+		*s = d.string()
+	}
+
+	route.Metric = d.varint()
+	route.Version = d.uvarint()
+	route.Status = RouteStatus(d.uvarint())
+	if version > 1 {
+		route.Class = RouteClass(d.uvarint())
+	}
+	if version > 2 {
+		route.Weight = int(d.varint())
+	}
+	route.LastSeen = d.time()
+	route.ActivateAt = d.time()
+
+	if n := d.uvarint(); n > 0 {
+		// every metadata entry takes at least two bytes
+		if n > uint64(len(d.b)) {
+			return ErrInvalidEncoding
+		}
+		route.Metadata = make(map[string]string, n)
+		for i := uint64(0); i < n; i++ {
+			k := d.string()
+			route.Metadata[k] = d.string()
+		}
+	}
+
+	if d.err != nil || len(d.b) > 0 {
+		return ErrInvalidEncoding
+	}
+
+	*r = route
+
+	return nil
+}
+
+// appendUvarint appends the uvarint encoded value
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+// appendVarint appends the varint encoded value
+func appendVarint(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+// appendString appends the length prefixed string
+func appendString(b []byte, s string) []byte {
+	b = appendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// appendTime appends the time as unix nanoseconds; zero time is encoded as 0
+func appendTime(b []byte, t time.Time) []byte {
+	if t.IsZero() {
+		return appendVarint(b, 0)
+	}
+	return appendVarint(b, t.UnixNano())
+}
+
+// decoder decodes the route binary fields remembering the first error
+type decoder struct {
+	b   []byte
+	err error
+}
+
+// uvarint decodes the next uvarint
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = ErrInvalidEncoding
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+// varint decodes the next varint
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.err = ErrInvalidEncoding
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+// string decodes the next length prefixed string
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err != nil {
+		return ""
+	}
+	if n > uint64(len(d.b)) {
+		d.err = ErrInvalidEncoding
+		return ""
+	}
+	s := string(d.b[:n])
+	d.b = d.b[n:]
+	return s
+}
+
+// time decodes the next unix nanoseconds time
+func (d *decoder) time() time.Time {
+	v := d.varint()
+	if v == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, v)
+}
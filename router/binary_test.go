@@ -0,0 +1,107 @@
+package router
+
+import (
+	"encoding"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var _ encoding.BinaryMarshaler = Route{}
+var _ encoding.BinaryUnmarshaler = &Route{}
+
+func TestRouteBinary(t *testing.T) {
+	route := Route{
+		Service:    "dest.svc",
+		Address:    "dest.addr",
+		Gateway:    "dest.gw",
+		Network:    "dest.network",
+		Router:     "src.router",
+		Link:       "det.link",
+		Metric:     -10,
+		Version:    3,
+		Status:     Draining,
+		Class:      Dynamic,
+		Weight:     25,
+		LastSeen:   time.Unix(0, time.Now().UnixNano()),
+		ActivateAt: time.Unix(1600000000, 0),
+		Metadata:   map[string]string{"group": "canary", "zone": "eu"},
+	}
+
+	b, err := route.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error encoding route: %s", err)
+	}
+
+	var decoded Route
+	if err := decoded.UnmarshalBinary(b); err != nil {
+		t.Fatalf("error decoding route: %s", err)
+	}
+
+	if !reflect.DeepEqual(decoded, route) {
+		t.Errorf("incorrect decoded route. Expected: %+v, found: %+v", route, decoded)
+	}
+
+	// zero values round trip too
+	b, err = Route{}.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error encoding route: %s", err)
+	}
+
+	decoded = Route{Service: "foo"}
+	if err := decoded.UnmarshalBinary(b); err != nil {
+		t.Fatalf("error decoding route: %s", err)
+	}
+
+	if !reflect.DeepEqual(decoded, Route{}) {
+		t.Errorf("expected empty route, found: %+v", decoded)
+	}
+}
+
+func TestRouteBinaryVersion(t *testing.T) {
+	b, err := Route{Service: "dest.svc"}.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error encoding route: %s", err)
+	}
+
+	b[0] = binaryVersion + 1
+
+	var route Route
+	if err := route.UnmarshalBinary(b); err != ErrUnsupportedEncoding {
+		t.Errorf("expected error: %s, found: %v", ErrUnsupportedEncoding, err)
+	}
+
+	// truncated encodings are rejected
+	b[0] = binaryVersion
+	if err := route.UnmarshalBinary(b[:len(b)-1]); err != ErrInvalidEncoding {
+		t.Errorf("expected error: %s, found: %v", ErrInvalidEncoding, err)
+	}
+
+	if err := route.UnmarshalBinary(nil); err != ErrInvalidEncoding {
+		t.Errorf("expected error: %s, found: %v", ErrInvalidEncoding, err)
+	}
+}
+
+func TestRouteBinaryVersion1(t *testing.T) {
+	route := Route{Service: "dest.svc", Status: Down, Class: Static}
+
+	b, err := route.MarshalBinary()
+	if err != nil {
+		t.Fatalf("error encoding route: %s", err)
+	}
+
+	// version 1 encoding has no class and weight following the status, which
+	// follows the version, the six strings and the metric and version varints
+	status := 1 + 1 + len(route.Service) + 5 + 2
+	v1 := append([]byte{1}, b[1:status+1]...)
+	v1 = append(v1, b[status+3:]...)
+
+	var decoded Route
+	if err := decoded.UnmarshalBinary(v1); err != nil {
+		t.Fatalf("error decoding version 1 route: %s", err)
+	}
+
+	if decoded.Status != Down || decoded.Class != Learned {
+		t.Errorf("incorrect decoded route: %+v", decoded)
+	}
+}
@@ -0,0 +1,129 @@
+package router
+
+import (
+	"errors"
+)
+
+var (
+	// DefaultEventRetention is the default number of the last events retained by the table
+	DefaultEventRetention = 1024
+	// ErrSeqTooOld is returned when the events following the sequence number are no longer retained
+	ErrSeqTooOld = errors.New("sequence number too old")
+	// ErrSeqInFuture is returned when the sequence number is greater than the sequence number
+	// of the last emitted event, e.g. it has been received from another table instance
+	ErrSeqInFuture = errors.New("sequence number in the future")
+)
+
+// retain appends the event to the retained events log. The events older than the retention
+// are trimmed by the compactor, see RunCompactor, or once the log has grown to twice the
+// retention. It must be called with the table lock held.
+func (t *MemoryTable) retain(e Event) {
+	if t.opts.EventRetention <= 0 {
+		return
+	}
+
+	t.log = append(t.log, e)
+	if len(t.log) >= 2*t.opts.EventRetention {
+		t.trimLog()
+	}
+}
+
+// retained returns the retained events. It must be called with the table lock held.
+func (t *MemoryTable) retained() []Event {
+	if n := len(t.log) - t.opts.EventRetention; n > 0 {
+		return t.log[n:]
+	}
+	return t.log
+}
+
+// trimLog trims the log to the retention, releasing the trimmed events, and returns
+// the number of the trimmed events. It must be called with the table lock held.
+func (t *MemoryTable) trimLog() int {
+	n := len(t.log) - t.opts.EventRetention
+	if n <= 0 {
+		return 0
+	}
+
+	log := make([]Event, t.opts.EventRetention)
+	copy(log, t.log[n:])
+	t.log = log
+
+	return n
+}
+
+// ChangedSince returns the retained events with sequence number greater than seq along with
+// the sequence number of the last emitted event, which should be passed to the next call.
+// It returns ErrSeqTooOld if some of the events following seq are no longer retained and
+// ErrSeqInFuture if seq has not been emitted yet, e.g. by the table before it has been
+// restarted, in which cases the consumer has to resync the full table state.
+func (t *MemoryTable) ChangedSince(seq uint64) ([]Event, uint64, error) {
+	t.RLock()
+	defer t.RUnlock()
+
+	return t.changedSince(seq)
+}
+
+// changedSince returns the retained events following seq. It must be called with the table lock held.
+func (t *MemoryTable) changedSince(seq uint64) ([]Event, uint64, error) {
+	if seq > t.seq {
+		return nil, t.seq, ErrSeqInFuture
+	}
+	if seq == t.seq {
+		return nil, t.seq, nil
+	}
+
+	log := t.retained()
+	if len(log) == 0 || seq+1 < log[0].Seq {
+		return nil, t.seq, ErrSeqTooOld
+	}
+
+	retained := log[seq+1-log[0].Seq:]
+	events := make([]Event, len(retained))
+	copy(events, retained)
+
+	return events, t.seq, nil
+}
+
+// EventLogStats describes the retained events log
+type EventLogStats struct {
+	// Size is the number of retained events
+	Size int
+	// Capacity is the maximum number of retained events
+	Capacity int
+	// Oldest is the sequence number of the oldest retained event
+	Oldest uint64
+	// Newest is the sequence number of the newest retained event
+	Newest uint64
+}
+
+// EventLog returns a copy of the retained events in sequence order
+func (t *MemoryTable) EventLog() []Event {
+	t.RLock()
+	defer t.RUnlock()
+
+	log := t.retained()
+	events := make([]Event, len(log))
+	copy(events, log)
+
+	return events
+}
+
+// EventLogStats returns the retained events log statistics. Resuming from a sequence number
+// lower than Oldest-1 returns ErrSeqTooOld.
+func (t *MemoryTable) EventLogStats() EventLogStats {
+	t.RLock()
+	defer t.RUnlock()
+
+	log := t.retained()
+	stats := EventLogStats{
+		Size:     len(log),
+		Capacity: t.opts.EventRetention,
+	}
+
+	if len(log) > 0 {
+		stats.Oldest = log[0].Seq
+		stats.Newest = log[len(log)-1].Seq
+	}
+
+	return stats
+}
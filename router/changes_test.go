@@ -0,0 +1,197 @@
+package router
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestChangedSince(t *testing.T) {
+	table := newTable(EventRetention(5))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	events, seq, err := table.ChangedSince(0)
+	if err != nil || len(events) != 0 || seq != 0 {
+		t.Fatalf("expected no changes, found: %d events, seq %d, error %v", len(events), seq, err)
+	}
+
+	var received []Event
+	var last uint64
+
+	// poll across several mutations
+	for i := 0; i < 3; i++ {
+		route.Address = fmt.Sprintf("10.0.0.%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+		if i%2 == 0 {
+			if err := table.Delete(route); err != nil {
+				t.Fatalf("error deleting route: %s", err)
+			}
+		}
+
+		events, seq, err := table.ChangedSince(last)
+		if err != nil {
+			t.Fatalf("error polling changes: %s", err)
+		}
+		received = append(received, events...)
+		last = seq
+	}
+
+	if len(received) != 5 {
+		t.Fatalf("expected 5 events, found: %d", len(received))
+	}
+
+	for i, e := range received {
+		if e.Seq != uint64(i+1) {
+			t.Errorf("expected event %d, found: %d", i+1, e.Seq)
+		}
+	}
+
+	if events, _, _ := table.ChangedSince(last); len(events) != 0 {
+		t.Errorf("expected no new changes, found: %d", len(events))
+	}
+
+	// the first events are no longer retained
+	for i := 0; i < 3; i++ {
+		route.Address = fmt.Sprintf("10.0.1.%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	if _, seq, err := table.ChangedSince(1); err != ErrSeqTooOld || seq != 8 {
+		t.Errorf("expected error: %s at seq 8, found: %v at seq %d", ErrSeqTooOld, err, seq)
+	}
+
+	// the sequence numbers which have not been emitted, e.g. by a restarted table, are rejected
+	if _, seq, err := table.ChangedSince(9); err != ErrSeqInFuture || seq != 8 {
+		t.Errorf("expected error: %s at seq 8, found: %v at seq %d", ErrSeqInFuture, err, seq)
+	}
+
+	events, seq, err = table.ChangedSince(last)
+	if err != nil {
+		t.Fatalf("error polling changes: %s", err)
+	}
+
+	if len(events) != 3 || seq != 8 || events[0].Seq != 6 {
+		t.Errorf("expected events 6 to 8, found: %d events at seq %d", len(events), seq)
+	}
+}
+
+func TestWatchResumeFrom(t *testing.T) {
+	table := newTable(EventRetention(5))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+
+	create := func(i int) {
+		route.Address = fmt.Sprintf("10.0.0.%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	create(1)
+	create(2)
+
+	// receive the first event and disconnect
+	var last uint64
+	for last != 1 {
+		e, err := w.Next()
+		if err != nil {
+			t.Fatalf("error receiving event: %s", err)
+		}
+		if e.Seq == 1 {
+			last = e.Seq
+		}
+	}
+	w.Stop()
+
+	create(3)
+
+	w, err = table.Watch(WatchResumeFrom(last))
+	if err != nil {
+		t.Fatalf("error resuming watcher: %s", err)
+	}
+	defer w.Stop()
+
+	create(4)
+
+	seen := make(map[uint64]bool)
+	for len(seen) < 3 {
+		e, err := w.Next()
+		if err != nil {
+			t.Fatalf("error receiving event: %s", err)
+		}
+		if seen[e.Seq] {
+			t.Errorf("duplicate event: %d", e.Seq)
+		}
+		seen[e.Seq] = true
+	}
+
+	for seq := uint64(2); seq <= 4; seq++ {
+		if !seen[seq] {
+			t.Errorf("missing event: %d", seq)
+		}
+	}
+
+	// the first events have aged out
+	for i := 5; i < 10; i++ {
+		create(i)
+	}
+
+	if _, err := table.Watch(WatchResumeFrom(1)); err != ErrSeqTooOld {
+		t.Errorf("expected error: %s, found: %v", ErrSeqTooOld, err)
+	}
+	if _, err := table.Watch(WatchResumeFrom(100)); err != ErrSeqInFuture {
+		t.Errorf("expected error: %s, found: %v", ErrSeqInFuture, err)
+	}
+}
+
+func TestEventLog(t *testing.T) {
+	table := newTable(EventRetention(3))
+	defer table.Close()
+
+	if stats := table.EventLogStats(); stats != (EventLogStats{Capacity: 3}) {
+		t.Errorf("unexpected empty log stats: %+v", stats)
+	}
+
+	_, route := testSetup()
+
+	for i := 0; i < 5; i++ {
+		route.Address = fmt.Sprintf("10.0.0.%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	// only the last events are retained
+	events := table.EventLog()
+	if len(events) != 3 {
+		t.Fatalf("expected 3 retained events, found: %d", len(events))
+	}
+
+	for i, e := range events {
+		if e.Seq != uint64(i+3) || e.Route.Address != fmt.Sprintf("10.0.0.%d", i+2) {
+			t.Errorf("unexpected retained event: %s", &e)
+		}
+	}
+
+	stats := table.EventLogStats()
+	if stats.Size != 3 || stats.Oldest != 3 || stats.Newest != 5 {
+		t.Errorf("unexpected log stats: %+v", stats)
+	}
+
+	// the returned log is a copy
+	events[0].Seq = 0
+	if table.EventLog()[0].Seq != 3 {
+		t.Errorf("expected log copy")
+	}
+}
@@ -0,0 +1,122 @@
+package router
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Checkpointer is a watcher which can checkpoint its position
+type Checkpointer interface {
+	// Checkpoint serializes the watcher options and the sequence number of the last event it has returned
+	Checkpoint() ([]byte, error)
+}
+
+// checkpoint is the serialized watcher position
+type checkpoint struct {
+	Service           string        `json:"service"`
+	Router            string        `json:"router"`
+	Network           string        `json:"network"`
+	Expr              string        `json:"expr,omitempty"`
+	AddressCIDR       string        `json:"address_cidr,omitempty"`
+	Priority          int           `json:"priority,omitempty"`
+	LevelTriggered    bool          `json:"level_triggered,omitempty"`
+	Limit             int           `json:"limit,omitempty"`
+	Exhausted         bool          `json:"exhausted,omitempty"`
+	Delivery          Delivery      `json:"delivery,omitempty"`
+	Resync            time.Duration `json:"resync,omitempty"`
+	Sample            bool          `json:"sample,omitempty"`
+	SampleFraction    float64       `json:"sample_fraction,omitempty"`
+	SampleByMetric    bool          `json:"sample_by_metric,omitempty"`
+	VisibilityTimeout time.Duration `json:"visibility_timeout,omitempty"`
+	Seq               uint64        `json:"seq"`
+}
+
+// advance records the sequence number of the event returned by the watcher
+func (w *tableWatcher) advance(e *Event) {
+	w.Lock()
+	w.advanceLocked(e)
+	w.Unlock()
+}
+
+// Checkpoint serializes the watcher options and the sequence number of the last event
+// returned by Next, which WatchFromCheckpoint resumes after. The events received from
+// the watcher channel are not tracked. Watchers replaying a WatchSince snapshot resume
+// after the snapshot and level triggered watchers resume before their oldest pending event. WatchMap functions can not be serialized and are not restored.
+// The position is exact with a single dispatch worker, see DispatchWorkers, otherwise
+// the events may be dispatched out of order and some of them redelivered or missed.
+func (w *tableWatcher) Checkpoint() ([]byte, error) {
+	w.RLock()
+	defer w.RUnlock()
+
+	c := checkpoint{
+		Service:           w.opts.Service,
+		Router:            w.opts.Router,
+		Network:           w.opts.Network,
+		Expr:              w.opts.Expr,
+		AddressCIDR:       w.opts.AddressCIDR,
+		Priority:          w.opts.Priority,
+		LevelTriggered:    w.opts.LevelTriggered,
+		Delivery:          w.opts.Delivery,
+		Resync:            w.opts.Resync,
+		Sample:            w.opts.Sample,
+		SampleFraction:    w.opts.SampleFraction,
+		SampleByMetric:    w.opts.SampleByMetric,
+		VisibilityTimeout: w.opts.VisibilityTimeout,
+		Seq:               w.position,
+	}
+
+	// the limited watchers keep their remaining limit, which can't be restored
+	// as zero meaning no limit
+	if w.opts.Limit > 0 {
+		c.Limit = w.opts.Limit - w.delivered + len(w.resChan)
+		c.Exhausted = c.Limit <= 0
+	}
+
+	// the pending events of level triggered watcher are replayed
+	for _, e := range w.latest {
+		if e.Seq > 0 && e.Seq <= c.Seq {
+			c.Seq = e.Seq - 1
+		}
+	}
+
+	return json.Marshal(c)
+}
+
+// WatchFromCheckpoint creates the watcher restored from the checkpoint, resuming
+// after its position. It returns ErrSeqTooOld if the events following the position
+// are no longer retained and ErrSeqInFuture if the position has not been emitted by
+// the table, e.g. it has been checkpointed before the table restart, in which cases
+// the consumer has to replay the table. Restoring the limited watcher which has
+// delivered all its events returns ErrWatcherStopped.
+func (t *MemoryTable) WatchFromCheckpoint(data []byte) (Watcher, error) {
+	var c checkpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+
+	if c.Exhausted {
+		return nil, ErrWatcherStopped
+	}
+
+	opts := []WatchOption{
+		WatchService(c.Service),
+		WatchRouter(c.Router),
+		WatchNetwork(c.Network),
+		WatchExpr(c.Expr),
+		WatchAddressCIDR(c.AddressCIDR),
+		WatchPriority(c.Priority),
+		WatchLimit(c.Limit),
+		WatchDelivery(c.Delivery),
+		WatchWithResync(c.Resync),
+		WatchVisibilityTimeout(c.VisibilityTimeout),
+		WatchResumeFrom(c.Seq),
+	}
+	if c.LevelTriggered {
+		opts = append(opts, WatchLevelTriggered())
+	}
+	if c.Sample {
+		opts = append(opts, WatchSample(c.SampleFraction, c.SampleByMetric))
+	}
+
+	return t.Watch(opts...)
+}
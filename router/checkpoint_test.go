@@ -0,0 +1,141 @@
+package router
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCheckpoint(t *testing.T) {
+	table := newTable(DispatchWorkers(1), EventRetention(10))
+	defer table.Close()
+
+	w, err := table.Watch(WatchService("dest.svc"), WatchPriority(5))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+
+	_, route := testSetup()
+
+	create := func(service string, i int) {
+		route.Service = service
+		route.Address = fmt.Sprintf("10.0.0.%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	for i := 1; i <= 3; i++ {
+		create("dest.svc", i)
+	}
+
+	// process the first two events before restarting
+	for i := 1; i <= 2; i++ {
+		e, err := w.Next()
+		if err != nil {
+			t.Fatalf("error receiving event: %s", err)
+		}
+		if e.Seq != uint64(i) {
+			t.Fatalf("expected event %d, found: %d", i, e.Seq)
+		}
+	}
+
+	data, err := w.(Checkpointer).Checkpoint()
+	if err != nil {
+		t.Fatalf("error checkpointing watcher: %s", err)
+	}
+	w.Stop()
+
+	create("other.svc", 4)
+	create("dest.svc", 5)
+
+	restored, err := table.WatchFromCheckpoint(data)
+	if err != nil {
+		t.Fatalf("error restoring watcher: %s", err)
+	}
+	defer restored.Stop()
+
+	if info := restored.(*tableWatcher).info(); info.Service != "dest.svc" || info.Priority != 5 {
+		t.Errorf("expected restored watch options, found: %+v", info)
+	}
+
+	// the restored watcher resumes after the checkpoint, filtering the other service routes
+	for _, seq := range []uint64{3, 5} {
+		e, err := restored.Next()
+		if err != nil {
+			t.Fatalf("error receiving event: %s", err)
+		}
+		if e.Seq != seq {
+			t.Errorf("expected event %d, found: %d", seq, e.Seq)
+		}
+	}
+
+	// checkpoints which are too old can not be restored
+	for i := 6; i < 20; i++ {
+		create("dest.svc", i)
+	}
+	if _, err := table.WatchFromCheckpoint(data); err != ErrSeqTooOld {
+		t.Errorf("expected error: %s, found: %v", ErrSeqTooOld, err)
+	}
+
+	// checkpoints of another table instance are ahead of the restarted table
+	restarted := newTable(DispatchWorkers(1), EventRetention(10))
+	defer restarted.Close()
+
+	if _, err := restarted.WatchFromCheckpoint(data); err != ErrSeqInFuture {
+		t.Errorf("expected error: %s, found: %v", ErrSeqInFuture, err)
+	}
+
+	if _, err := table.WatchFromCheckpoint([]byte("invalid")); err == nil {
+		t.Errorf("expected error restoring invalid checkpoint")
+	}
+}
+
+func TestCheckpointLimit(t *testing.T) {
+	table := newTable(DispatchWorkers(1))
+	defer table.Close()
+
+	w, err := table.Watch(WatchLimit(2), WatchSample(0.5, true), WatchVisibilityTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	data, err := w.(Checkpointer).Checkpoint()
+	if err != nil {
+		t.Fatalf("error checkpointing watcher: %s", err)
+	}
+
+	restored, err := table.WatchFromCheckpoint(data)
+	if err != nil {
+		t.Fatalf("error restoring watcher: %s", err)
+	}
+	opts := restored.(*tableWatcher).opts
+	restored.Stop()
+
+	if opts.Limit != 2 || opts.SampleFraction != 0.5 || !opts.SampleByMetric || opts.VisibilityTimeout != time.Second {
+		t.Errorf("expected restored watch options, found: %+v", opts)
+	}
+
+	// the watcher which has delivered all its events is not restored as unlimited
+	limited, err := table.Watch(WatchLimit(1))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+
+	_, route := testSetup()
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+	if _, err := limited.Next(); err != nil {
+		t.Fatalf("error receiving event: %s", err)
+	}
+
+	data, err = limited.(Checkpointer).Checkpoint()
+	if err != nil {
+		t.Fatalf("error checkpointing watcher: %s", err)
+	}
+	if _, err := table.WatchFromCheckpoint(data); err != ErrWatcherStopped {
+		t.Errorf("expected error: %s, found: %v", ErrWatcherStopped, err)
+	}
+}
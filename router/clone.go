@@ -0,0 +1,91 @@
+package router
+
+// Clone returns an independent deep copy of the table, e.g. to simulate changes before
+// applying them. The clone has the same routes, aliases, federations, leases, dependencies
+// and retained events as the table and uses the same options, but it has no watchers and
+// its mutations do not affect the table. Compare the routes of the table and the clone with
+// Diff to preview the simulated changes. The clone must be closed once it is no longer used.
+//
+// So that simulating the changes has no side effects the clone does not report to the latency
+// and compaction observers nor call the watcher hooks, and the route metrics do not decay nor
+// the routes expire on their own. The options passed to Clone are applied on top of the table
+// options, e.g. to enable any of them for the clone.
+func (t *MemoryTable) Clone(opts ...TableOption) *MemoryTable {
+	c := newTable(append([]TableOption{func(o *TableOptions) {
+		*o = t.opts
+		o.LatencyObserver = nil
+		o.CompactionObserver = nil
+		o.OnWatcherAdded = nil
+		o.OnWatcherRemoved = nil
+		o.DecayRate = 0
+		o.DecayInterval = 0
+		o.RouteTTL = 0
+		o.StaleThreshold = 0
+		o.DynamicRouteTTL = 0
+	}}, opts...)...)
+
+	t.RLock()
+	defer t.RUnlock()
+
+	for service, rmap := range t.routes {
+		c.routes[service] = make(map[uint64]Route, len(rmap))
+		for sum, route := range rmap {
+			if route.Metadata != nil {
+				route.Metadata = copyMetadata(route.Metadata)
+			}
+			c.setRoute(sum, route)
+		}
+	}
+
+	for sum, route := range t.pending {
+		if route.Metadata != nil {
+			route.Metadata = copyMetadata(route.Metadata)
+		}
+		c.pending[sum] = route
+	}
+	if len(c.pending) > 0 {
+		c.startScheduler()
+	}
+
+	for sum, metric := range t.baselines {
+		c.baselines[sum] = metric
+	}
+	for sum, decay := range t.decayed {
+		c.decayed[sum] = decay
+	}
+
+	for network, peers := range t.federation {
+		c.federation[network] = make(map[string]bool, len(peers))
+		for peer := range peers {
+			c.federation[network][peer] = true
+		}
+	}
+
+	for key, l := range t.leases {
+		c.leases[key] = l
+	}
+
+	for id, deps := range t.requires {
+		for dep := range deps {
+			if c.requires[id] == nil {
+				c.requires[id] = make(map[string]bool)
+			}
+			c.requires[id][dep] = true
+			if c.dependents[dep] == nil {
+				c.dependents[dep] = make(map[string]bool)
+			}
+			c.dependents[dep][id] = true
+		}
+	}
+
+	c.seq = t.seq
+	log := t.retained()
+	c.log = make([]Event, len(log))
+	copy(c.log, log)
+
+	for alias, service := range t.Aliases() {
+		c.aliases[alias] = service
+	}
+
+	return c
+}
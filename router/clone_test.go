@@ -0,0 +1,113 @@
+package router
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClone(t *testing.T) {
+	original, route := testSetup()
+	defer original.Close()
+
+	route.Metadata = map[string]string{"zone": "eu"}
+	if err := original.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	w, err := original.Watch()
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	clone := original.Clone()
+	defer clone.Close()
+
+	if clone.Version() != original.Version() || clone.Fingerprint() != original.Fingerprint() {
+		t.Fatalf("expected clone identical to the table")
+	}
+
+	// simulate the changes on the clone
+	updated := route
+	updated.Metadata = map[string]string{"zone": "us"}
+	updated.Metric = 50
+	if err := clone.Update(updated); err != nil {
+		t.Fatalf("error updating cloned route: %s", err)
+	}
+
+	created := route
+	created.Address = "dest.addr-new"
+	if err := clone.Create(created); err != nil {
+		t.Fatalf("error adding cloned route: %s", err)
+	}
+
+	// the table is not affected
+	routes, _ := original.List()
+	if len(routes) != 1 || routes[0].Metric != route.Metric || routes[0].Metadata["zone"] != "eu" {
+		t.Errorf("expected table not affected by the clone, found: %+v", routes)
+	}
+
+	if e, _, err := original.ChangedSince(1); err != nil || len(e) != 0 {
+		t.Errorf("expected no table events, found: %d", len(e))
+	}
+
+	// the create event may be dispatched late
+	for _, e := range collectEvents(w, 100*time.Millisecond) {
+		if e.Seq > 1 {
+			t.Errorf("expected no events for table watchers, found: %s", e)
+		}
+	}
+
+	cloned, _ := clone.List()
+
+	types := make(map[EventType]int)
+	for _, e := range Diff(routes, cloned) {
+		types[e.Type]++
+	}
+	if len(types) != 2 || types[Create] != 1 || types[Update] != 1 {
+		t.Errorf("expected diff with 1 create and 1 update, found: %v", types)
+	}
+}
+
+func TestCloneSideEffects(t *testing.T) {
+	var observed, hooked int32
+	original := newTable(
+		TableLatencyObserver(func(string, time.Duration) { atomic.AddInt32(&observed, 1) }),
+		OnWatcherAdded(func(WatcherInfo) { atomic.AddInt32(&hooked, 1) }),
+		MetricDecay(1, time.Millisecond),
+		RouteTTL(time.Minute),
+	)
+	defer original.Close()
+
+	clone := original.Clone()
+	defer clone.Close()
+
+	_, route := testSetup()
+	if err := clone.Create(route); err != nil {
+		t.Fatalf("error adding cloned route: %s", err)
+	}
+
+	w, err := clone.Watch()
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	w.Stop()
+
+	if atomic.LoadInt32(&observed) != 0 || atomic.LoadInt32(&hooked) != 0 {
+		t.Errorf("expected the clone not to report to the table observers and hooks")
+	}
+
+	opts := clone.opts
+	if opts.DecayRate != 0 || opts.RouteTTL != 0 || opts.DynamicRouteTTL != 0 {
+		t.Errorf("expected the clone not to run the background processes, found: %+v", opts)
+	}
+
+	// the overrides are applied to the clone
+	decaying := original.Clone(MetricDecay(1, time.Millisecond))
+	defer decaying.Close()
+
+	if decaying.opts.DecayRate != 1 {
+		t.Errorf("expected the clone decaying the metrics, found rate: %d", decaying.opts.DecayRate)
+	}
+}
@@ -0,0 +1,174 @@
+package router
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// maxRecordSize is the maximum size of an exported route
+const maxRecordSize = 1 << 20
+
+// ErrRecordTooLarge is returned when an exported route exceeds the maximum size
+var ErrRecordTooLarge = errors.New("route record too large")
+
+// Codec encodes and decodes routes
+type Codec interface {
+	// Marshal encodes the route
+	Marshal(Route) ([]byte, error)
+	// Unmarshal decodes the route
+	Unmarshal([]byte) (Route, error)
+	// String returns the codec name
+	String() string
+}
+
+// jsonCodec encodes routes as JSON
+type jsonCodec struct{}
+
+// NewJSONCodec returns a codec encoding routes as JSON
+func NewJSONCodec() Codec {
+	return jsonCodec{}
+}
+
+func (jsonCodec) Marshal(r Route) ([]byte, error) {
+	return json.Marshal(r)
+}
+
+func (jsonCodec) Unmarshal(b []byte) (Route, error) {
+	var r Route
+	err := json.Unmarshal(b, &r)
+	return r, err
+}
+
+func (jsonCodec) String() string {
+	return "json"
+}
+
+// Export writes all the routes in the table encoded with the table codec.
+// Each encoded route is prefixed with its length as uvarint. It returns ErrRecordTooLarge
+// if an encoded route exceeds the maximum record size of 1 MiB.
+func (t *MemoryTable) Export(w io.Writer) error {
+	routes, err := t.List()
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, binary.MaxVarintLen64)
+
+	for _, route := range routes {
+		b, err := t.opts.Codec.Marshal(route)
+		if err != nil {
+			return err
+		}
+		if len(b) > maxRecordSize {
+			return ErrRecordTooLarge
+		}
+
+		n := binary.PutUvarint(buf, uint64(len(b)))
+		if _, err := w.Write(buf[:n]); err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ImportRejection describes a route rejected by Import
+type ImportRejection struct {
+	// Index is the position of the route in the import stream
+	Index int
+	// Route is the rejected route, empty if it could not be decoded
+	Route Route
+	// Err is the reason of the rejection
+	Err error
+}
+
+// ImportReport summarises the routes processed by Import
+type ImportReport struct {
+	// Accepted is the number of routes added into the table
+	Accepted int
+	// Rejected lists the rejected routes
+	Rejected []ImportRejection
+}
+
+// validateRoute checks the route can be added into the table
+func validateRoute(r Route) error {
+	if len(r.Service) == 0 || len(r.Address) == 0 {
+		return ErrInvalidRoute
+	}
+	return nil
+}
+
+// Import reads the routes written by Export and adds them into the table.
+// Routes which already exist in the table are updated. Import stops on the
+// first invalid route unless ImportSkipInvalid is set, in which case the
+// invalid routes are listed in the returned report.
+func (t *MemoryTable) Import(r io.Reader, opts ...ImportOption) (ImportReport, error) {
+	var options ImportOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	var report ImportReport
+	br := bufio.NewReader(r)
+
+	for i := 0; ; i++ {
+		size, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			return report, nil
+		}
+		if err != nil {
+			return report, err
+		}
+		// the size is read from the input so it can't be trusted
+		if size > maxRecordSize {
+			return report, ErrRecordTooLarge
+		}
+
+		b := make([]byte, size)
+		if _, err := io.ReadFull(br, b); err != nil {
+			return report, err
+		}
+
+		route, err := t.opts.Codec.Unmarshal(b)
+		if err == nil {
+			err = validateRoute(route)
+		}
+		if err == nil {
+			err = t.importRoute(route)
+		}
+		if err != nil {
+			report.Rejected = append(report.Rejected, ImportRejection{Index: i, Route: route, Err: err})
+			if !options.SkipInvalid {
+				return report, err
+			}
+			continue
+		}
+
+		report.Accepted++
+	}
+}
+
+// importRoute creates the route or updates it if it already exists.
+// It returns ErrLeaseHeld if the route is leased by another router.
+func (t *MemoryTable) importRoute(r Route) error {
+	r = t.normalize(r)
+
+	t.Lock()
+	defer t.Unlock()
+
+	if err := t.checkLease(r); err != nil {
+		return err
+	}
+
+	if err := t.create(r, CauseSync); err != ErrDuplicateRoute {
+		return err
+	}
+
+	return t.update(r, CauseSync)
+}
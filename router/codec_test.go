@@ -0,0 +1,165 @@
+package router
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testCodec encodes routes as pipe separated fields
+type testCodec struct{}
+
+func (testCodec) Marshal(r Route) ([]byte, error) {
+	return []byte(strings.Join([]string{r.Service, r.Address, r.Gateway, r.Network, r.Router, r.Link, strconv.FormatInt(r.Metric, 10)}, "|")), nil
+}
+
+func (testCodec) Unmarshal(b []byte) (Route, error) {
+	parts := strings.Split(string(b), "|")
+	if len(parts) != 7 {
+		return Route{}, fmt.Errorf("invalid route: %s", b)
+	}
+	metric, err := strconv.ParseInt(parts[6], 10, 64)
+	if err != nil {
+		return Route{}, err
+	}
+	return Route{
+		Service: parts[0],
+		Address: parts[1],
+		Gateway: parts[2],
+		Network: parts[3],
+		Router:  parts[4],
+		Link:    parts[5],
+		Metric:  metric,
+	}, nil
+}
+
+func (testCodec) String() string {
+	return "test"
+}
+
+func TestCodec(t *testing.T) {
+	for _, codec := range []Codec{NewJSONCodec(), testCodec{}} {
+		table := newTable(TableCodec(codec))
+		_, route := testSetup()
+
+		for i := 0; i < 3; i++ {
+			route.Address = fmt.Sprintf("dest.addr-%d", i)
+			route.Metric = int64(i)
+			if err := table.Create(route); err != nil {
+				t.Fatalf("error adding route: %s", err)
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := table.Export(&buf); err != nil {
+			t.Fatalf("%s codec: error exporting routes: %s", codec, err)
+		}
+
+		restored := newTable(TableCodec(codec))
+		if _, err := restored.Import(&buf); err != nil {
+			t.Fatalf("%s codec: error importing routes: %s", codec, err)
+		}
+
+		if restored.Version() != table.Version() {
+			t.Errorf("%s codec: restored table differs from the original", codec)
+		}
+	}
+}
+
+func TestCodecError(t *testing.T) {
+	table := newTable(TableCodec(testCodec{}))
+
+	// a JSON encoded route can't be decoded by the test codec
+	b, _ := NewJSONCodec().Marshal(Route{Service: "foo"})
+	data := append([]byte{byte(len(b))}, b...)
+
+	if _, err := table.Import(bytes.NewReader(data)); err == nil {
+		t.Errorf("expected error importing invalid route")
+	}
+}
+
+func TestImportRecordSize(t *testing.T) {
+	table := newTable()
+
+	// the length prefix exceeds the maximum record size
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, 1<<40)
+
+	if _, err := table.Import(bytes.NewReader(buf[:n])); err != ErrRecordTooLarge {
+		t.Errorf("expected error: %s, found: %v", ErrRecordTooLarge, err)
+	}
+
+	// the record is shorter than its length prefix
+	b, _ := NewJSONCodec().Marshal(Route{Service: "foo", Address: "10.0.0.1"})
+	n = binary.PutUvarint(buf, uint64(len(b)))
+	data := append(buf[:n:n], b[:len(b)/2]...)
+
+	if _, err := table.Import(bytes.NewReader(data)); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected error: %s, found: %v", io.ErrUnexpectedEOF, err)
+	}
+
+	if routes, _ := table.List(); len(routes) != 0 {
+		t.Errorf("expected no routes imported, found: %d", len(routes))
+	}
+}
+
+func TestImportReport(t *testing.T) {
+	table := newTable(TableCodec(testCodec{}))
+	_, route := testSetup()
+
+	var buf bytes.Buffer
+	write := func(b []byte) {
+		lb := make([]byte, binary.MaxVarintLen64)
+		n := binary.PutUvarint(lb, uint64(len(b)))
+		buf.Write(lb[:n])
+		buf.Write(b)
+	}
+
+	valid, _ := testCodec{}.Marshal(route)
+	write(valid)
+	// undecodable route
+	write([]byte("foo|bar"))
+	// route missing its address
+	noAddr := route
+	noAddr.Address = ""
+	b, _ := testCodec{}.Marshal(noAddr)
+	write(b)
+	route.Address = "dest.addr-1"
+	valid, _ = testCodec{}.Marshal(route)
+	write(valid)
+
+	data := buf.Bytes()
+
+	// the import stops on the first invalid route by default
+	report, err := table.Import(bytes.NewReader(data))
+	if err == nil {
+		t.Errorf("expected error importing invalid route")
+	}
+	if report.Accepted != 1 || len(report.Rejected) != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	report, err = newTable(TableCodec(testCodec{})).Import(bytes.NewReader(data), ImportSkipInvalid(true))
+	if err != nil {
+		t.Fatalf("error importing routes: %s", err)
+	}
+	if report.Accepted != 2 {
+		t.Errorf("expected 2 accepted routes, found: %d", report.Accepted)
+	}
+	if len(report.Rejected) != 2 {
+		t.Fatalf("expected 2 rejected routes, found: %d", len(report.Rejected))
+	}
+	if report.Rejected[0].Index != 1 || report.Rejected[0].Err == nil {
+		t.Errorf("unexpected rejection: %+v", report.Rejected[0])
+	}
+	if report.Rejected[1].Index != 2 || report.Rejected[1].Err != ErrInvalidRoute {
+		t.Errorf("unexpected rejection: %+v", report.Rejected[1])
+	}
+	if report.Rejected[1].Route.Service != route.Service {
+		t.Errorf("expected rejected route service: %s, found: %s", route.Service, report.Rejected[1].Route.Service)
+	}
+}
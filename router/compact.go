@@ -0,0 +1,84 @@
+package router
+
+import (
+	"time"
+)
+
+// CompactionStats describes the state reclaimed by a table compaction
+type CompactionStats struct {
+	// Events is the number of the events trimmed from the retained events log
+	Events int
+	// Leases is the number of the expired leases removed
+	Leases int
+	// Shadowed is the number of the shadowed routes deleted
+	Shadowed int
+}
+
+// RunCompactor periodically compacts the table until it is closed. Each compaction trims the
+// retained events log to the event retention, removes the expired leases and, if enabled with
+// CompactShadowed, deletes the shadowed routes. The table keeps no tombstones of the deleted
+// routes so there are none to remove. Without the compactor the log is only trimmed once it has
+// grown to twice the event retention. The compaction statistics are reported
+// to the observer set with TableCompactionObserver.
+func (t *MemoryTable) RunCompactor(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	t.run(func() {
+		t.compactor(interval)
+	})
+}
+
+// compactor periodically compacts the table until the table is closed
+func (t *MemoryTable) compactor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.exit:
+			return
+		case <-ticker.C:
+			stats := t.compact()
+			if t.opts.CompactionObserver != nil {
+				t.opts.CompactionObserver(stats)
+			}
+		}
+	}
+}
+
+// compact reclaims the stale table state and returns what has been reclaimed
+func (t *MemoryTable) compact() CompactionStats {
+	t.Lock()
+	defer t.Unlock()
+
+	var stats CompactionStats
+
+	now := t.opts.Clock()
+	for key, l := range t.leases {
+		if !now.Before(l.expiry) {
+			delete(t.leases, key)
+			stats.Leases++
+		}
+	}
+
+	if t.opts.CompactShadowed {
+		for _, rmap := range t.routes {
+			for _, route := range shadowedRoutes(rmap, t.opts.ShadowFactor) {
+				// static routes are only ever deleted explicitly
+				if route.Class == Static {
+					continue
+				}
+				if err := t.remove(route, CauseCompaction); err == nil {
+					stats.Shadowed++
+				}
+			}
+		}
+	}
+
+	// the log is trimmed last as deleting the shadowed routes appends to it
+	stats.Events = t.trimLog()
+
+	return stats
+}
@@ -0,0 +1,149 @@
+package router
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCompact(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	table := newTable(EventRetention(3), CompactShadowed(true), TableClock(clock))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	metrics := map[string]int64{
+		"10.0.0.1": 1,
+		"10.0.0.2": 5,
+		"10.0.0.3": 100,
+	}
+	for address, metric := range metrics {
+		r := route
+		r.Address = address
+		r.Metric = metric
+		if err := table.Create(r); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	// the static shadowed route is kept
+	static := route
+	static.Address = "10.0.0.4"
+	static.Metric = 100
+	static.Class = Static
+	if err := table.Create(static); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	for _, address := range []string{"10.0.0.1", "10.0.0.2"} {
+		if err := table.AcquireLease(route.Service, address, route.Router, time.Minute); err != nil {
+			t.Fatalf("error acquiring lease: %s", err)
+		}
+	}
+	now = now.Add(2 * time.Minute)
+	if err := table.AcquireLease(route.Service, "10.0.0.1", route.Router, time.Minute); err != nil {
+		t.Fatalf("error acquiring lease: %s", err)
+	}
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error watching table: %s", err)
+	}
+	defer w.Stop()
+
+	// only the events within the retention are returned before the log is compacted
+	if log := table.EventLog(); len(log) != 3 || log[0].Seq != 2 {
+		t.Fatalf("expected the 3 last events to be retained, found: %v", log)
+	}
+
+	// the 4 created routes and the deleted shadowed route are trimmed to the retention
+	stats := table.compact()
+	if stats.Events != 2 || stats.Leases != 1 || stats.Shadowed != 1 {
+		t.Errorf("expected 2 events, 1 lease and 1 shadowed route compacted, found: %+v", stats)
+	}
+
+	if len(table.log) != 3 {
+		t.Errorf("expected log trimmed to the 3 last events, found: %d", len(table.log))
+	}
+	if log := table.EventLog(); len(log) != 3 || log[0].Seq != 3 || log[2].Cause != CauseCompaction {
+		t.Errorf("expected compacted log of the 3 last events, found: %v", log)
+	}
+	if len(table.leases) != 1 {
+		t.Errorf("expected active lease to be kept, found %d leases", len(table.leases))
+	}
+
+	routes, _ := table.List()
+	for _, r := range routes {
+		if r.Address == "10.0.0.3" {
+			t.Errorf("expected shadowed route to be deleted")
+		}
+	}
+	if len(routes) != 3 {
+		t.Errorf("expected 3 routes, found: %d", len(routes))
+	}
+
+	var deleted bool
+	for _, e := range collectEvents(w, 100*time.Millisecond) {
+		if e.Type != Delete {
+			continue
+		}
+		if e.Cause != CauseCompaction || e.Route.Address != "10.0.0.3" {
+			t.Errorf("expected delete of the shadowed route caused by %s, found: %s", CauseCompaction, e)
+		}
+		deleted = true
+	}
+	if !deleted {
+		t.Errorf("expected delete event")
+	}
+
+	// nothing is left to reclaim
+	if stats := table.compact(); stats != (CompactionStats{}) {
+		t.Errorf("expected nothing compacted, found: %+v", stats)
+	}
+}
+
+func TestRunCompactor(t *testing.T) {
+	compacted := make(chan CompactionStats, 1)
+	observer := func(stats CompactionStats) {
+		select {
+		case compacted <- stats:
+		default:
+		}
+	}
+
+	table := newTable(EventRetention(2), TableCompactionObserver(observer))
+
+	_, route := testSetup()
+	for i := 0; i < 3; i++ {
+		route.Address = fmt.Sprintf("10.0.0.%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	table.RunCompactor(10 * time.Millisecond)
+
+	select {
+	case stats := <-compacted:
+		if stats.Events != 1 {
+			t.Errorf("expected the oldest event to be trimmed, found: %+v", stats)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected compaction")
+	}
+
+	// closing the table stops the compactor
+	table.Close()
+	for len(compacted) > 0 {
+		<-compacted
+	}
+
+	select {
+	case stats := <-compacted:
+		t.Errorf("unexpected compaction after close: %+v", stats)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,139 @@
+package router
+
+import (
+	"sort"
+)
+
+// CostFunc returns the cost of the route; routes with lower cost are preferred
+type CostFunc func(Route) float64
+
+// MetricCost is the default cost function returning the route metric
+func MetricCost(r Route) float64 {
+	return float64(r.Metric)
+}
+
+// SelectByCost returns the healthy route with the lowest cost, like LookupWithRank, or the
+// route with the lowest cost if none of the routes is healthy. Ties are broken by address.
+// It returns an empty route if there are no routes.
+func SelectByCost(routes []Route, cost CostFunc) Route {
+	var best Route
+	var bestCost float64
+
+	for i, route := range routes {
+		c := cost(route)
+		if i == 0 || preferred(route, c, best, bestCost) {
+			best = route
+			bestCost = c
+		}
+	}
+
+	return best
+}
+
+// preferred returns true if the route with cost c is preferred to the best route with bestCost
+func preferred(route Route, c float64, best Route, bestCost float64) bool {
+	if healthy := route.Status == Healthy; healthy != (best.Status == Healthy) {
+		return healthy
+	}
+	if c != bestCost {
+		return c < bestCost
+	}
+	return route.Address < best.Address
+}
+
+// BestRoute returns the lowest cost healthy route to the destination service or the lowest
+// cost route if none of the routes is healthy. If cost is nil the table cost function is used.
+func (t *MemoryTable) BestRoute(service string, cost CostFunc) (Route, error) {
+	if cost == nil {
+		cost = t.opts.CostFunc
+	}
+
+	t.RLock()
+	defer t.RUnlock()
+
+	rmap := t.routes[t.resolve(service)]
+	if len(rmap) == 0 {
+		return Route{}, ErrRouteNotFound
+	}
+
+	routes := make([]Route, 0, len(rmap))
+	for _, route := range rmap {
+		routes = append(routes, route)
+	}
+
+	return SelectByCost(routes, cost), nil
+}
+
+// LookupWithRank returns the routes to the destination service sorted by ascending cost,
+// with ties broken by address, along with the index of the best route which is the
+// lowest cost healthy route or the lowest cost route if none of the routes is healthy.
+// The routes are captured under a single read lock so the result is a consistent snapshot.
+// If cost is nil the table cost function is used.
+func (t *MemoryTable) LookupWithRank(service string, cost CostFunc) ([]Route, int, error) {
+	if cost == nil {
+		cost = t.opts.CostFunc
+	}
+
+	t.RLock()
+	rmap := t.routes[t.resolve(service)]
+	routes := make([]Route, 0, len(rmap))
+	for _, route := range rmap {
+		routes = append(routes, route)
+	}
+	t.RUnlock()
+
+	if len(routes) == 0 {
+		return nil, 0, ErrRouteNotFound
+	}
+
+	costs := make(map[uint64]float64, len(routes))
+	for _, route := range routes {
+		costs[t.hash(route)] = cost(route)
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		ci, cj := costs[t.hash(routes[i])], costs[t.hash(routes[j])]
+		if ci != cj {
+			return ci < cj
+		}
+		return routes[i].Address < routes[j].Address
+	})
+
+	for i, route := range routes {
+		if route.Status == Healthy {
+			return routes, i, nil
+		}
+	}
+
+	return routes, 0, nil
+}
+
+// LookupPreferLocal returns the routes to the destination service in the local network
+// followed by the routes in the other networks. The routes of each group are sorted by
+// ascending metric, with ties broken by address.
+func (t *MemoryTable) LookupPreferLocal(service, localNetwork string) ([]Route, error) {
+	t.RLock()
+	rmap := t.routes[t.resolve(service)]
+	routes := make([]Route, 0, len(rmap))
+	for _, route := range rmap {
+		routes = append(routes, route)
+	}
+	t.RUnlock()
+
+	if len(routes) == 0 {
+		return nil, ErrRouteNotFound
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		li, lj := routes[i].Network == localNetwork, routes[j].Network == localNetwork
+		if li != lj {
+			return li
+		}
+		if routes[i].Metric != routes[j].Metric {
+			return routes[i].Metric < routes[j].Metric
+		}
+		return routes[i].Address < routes[j].Address
+	})
+
+	return routes, nil
+}
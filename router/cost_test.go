@@ -0,0 +1,200 @@
+package router
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestBestRoute(t *testing.T) {
+	table, route := testSetup()
+
+	// metric and latency of each route
+	data := []struct {
+		metric  int64
+		latency string
+	}{
+		{10, "100"},
+		{20, "5"},
+		{5, "200"},
+	}
+
+	for i, d := range data {
+		route.Address = fmt.Sprintf("dest.addr-%d", i)
+		route.Metric = d.metric
+		route.Metadata = map[string]string{"latency": d.latency}
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	// the default cost is the metric
+	best, err := table.BestRoute(route.Service, nil)
+	if err != nil {
+		t.Fatalf("error selecting route: %s", err)
+	}
+
+	if best.Address != "dest.addr-2" {
+		t.Errorf("incorrect best route. Expected: %s, found: %s", "dest.addr-2", best.Address)
+	}
+
+	// blend metric and latency
+	cost := func(r Route) float64 {
+		latency, _ := strconv.ParseFloat(r.Metadata["latency"], 64)
+		return 0.5*float64(r.Metric) + 0.5*latency
+	}
+
+	best, err = table.BestRoute(route.Service, cost)
+	if err != nil {
+		t.Fatalf("error selecting route: %s", err)
+	}
+
+	if best.Address != "dest.addr-1" {
+		t.Errorf("incorrect best route. Expected: %s, found: %s", "dest.addr-1", best.Address)
+	}
+
+	if _, err := table.BestRoute("missing.svc", cost); err != ErrRouteNotFound {
+		t.Errorf("expected error: %s, found: %v", ErrRouteNotFound, err)
+	}
+
+	if r := SelectByCost(nil, cost); len(r.Service) > 0 {
+		t.Errorf("expected empty route, found: %+v", r)
+	}
+
+	// the healthy routes are preferred to the lower cost ones
+	routes, _ := table.List()
+	for _, r := range routes {
+		if r.Address != "dest.addr-0" {
+			r.Status = Draining
+			if err := table.Update(r); err != nil {
+				t.Fatalf("error updating route: %s", err)
+			}
+		}
+	}
+
+	if best, _ := table.BestRoute(route.Service, nil); best.Address != "dest.addr-0" {
+		t.Errorf("incorrect best route. Expected: %s, found: %s", "dest.addr-0", best.Address)
+	}
+
+	// without any healthy route the lowest cost one is selected
+	routes, _ = table.List()
+	for i := range routes {
+		routes[i].Status = Down
+	}
+
+	if best := SelectByCost(routes, MetricCost); best.Address != "dest.addr-2" {
+		t.Errorf("incorrect best route. Expected: %s, found: %s", "dest.addr-2", best.Address)
+	}
+}
+
+func TestLookupWithRank(t *testing.T) {
+	table, route := testSetup()
+
+	for i := 0; i < 5; i++ {
+		route.Address = fmt.Sprintf("dest.addr-%d", i)
+		route.Metric = int64(10 - i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	// the lowest cost route is down
+	down := route
+	down.Status = Down
+	if err := table.Update(down); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+
+	done := make(chan bool)
+	defer close(done)
+
+	// keep mutating the route metrics
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			r := route
+			r.Address = fmt.Sprintf("dest.addr-%d", i%4)
+			r.Metric = int64(i % 20)
+			table.Update(r)
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		routes, best, err := table.LookupWithRank(route.Service, nil)
+		if err != nil {
+			t.Fatalf("error looking up routes: %s", err)
+		}
+
+		if len(routes) != 5 {
+			t.Fatalf("expected 5 routes, found: %d", len(routes))
+		}
+
+		for j := 1; j < len(routes); j++ {
+			if routes[j].Metric < routes[j-1].Metric {
+				t.Fatalf("routes not sorted by cost: %d after %d", routes[j].Metric, routes[j-1].Metric)
+			}
+		}
+
+		if routes[best].Status != Healthy {
+			t.Fatalf("expected healthy best route, found: %s", routes[best].Status)
+		}
+
+		for _, r := range routes[:best] {
+			if r.Status == Healthy {
+				t.Fatalf("found healthy route %s ranked before the best route", r.Address)
+			}
+		}
+	}
+
+	if _, _, err := table.LookupWithRank("missing.svc", nil); err != ErrRouteNotFound {
+		t.Errorf("expected error: %s, found: %v", ErrRouteNotFound, err)
+	}
+}
+
+func TestLookupPreferLocal(t *testing.T) {
+	table, route := testSetup()
+
+	data := []struct {
+		address string
+		network string
+		metric  int64
+	}{
+		{"remote-1", "remote", 1},
+		{"local-2", "local", 20},
+		{"remote-2", "remote", 5},
+		{"local-1", "local", 10},
+	}
+
+	for _, d := range data {
+		route.Address = d.address
+		route.Network = d.network
+		route.Metric = d.metric
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	routes, err := table.LookupPreferLocal(route.Service, "local")
+	if err != nil {
+		t.Fatalf("error looking up routes: %s", err)
+	}
+
+	expected := []string{"local-1", "local-2", "remote-1", "remote-2"}
+	if len(routes) != len(expected) {
+		t.Fatalf("expected %d routes, found: %d", len(expected), len(routes))
+	}
+
+	for i, address := range expected {
+		if routes[i].Address != address {
+			t.Errorf("incorrect route at %d. Expected: %s, found: %s", i, address, routes[i].Address)
+		}
+	}
+
+	if _, err := table.LookupPreferLocal("missing.svc", "local"); err != ErrRouteNotFound {
+		t.Errorf("expected error: %s, found: %v", ErrRouteNotFound, err)
+	}
+}
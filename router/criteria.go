@@ -0,0 +1,51 @@
+package router
+
+import (
+	"net"
+)
+
+// watchCriteria are the parsed watch filters
+type watchCriteria struct {
+	// expr is the parsed filter expression
+	expr expr
+	// cidr is the parsed address subnet
+	cidr *net.IPNet
+}
+
+// parseCriteria parses the watch filter expression and address CIDR
+func parseCriteria(o WatchOptions) (watchCriteria, error) {
+	var c watchCriteria
+
+	if len(o.Expr) > 0 {
+		e, err := parseExpr(o.Expr)
+		if err != nil {
+			return c, err
+		}
+		c.expr = e
+	}
+
+	if len(o.AddressCIDR) > 0 {
+		_, cidr, err := net.ParseCIDR(o.AddressCIDR)
+		if err != nil {
+			return c, err
+		}
+		c.cidr = cidr
+	}
+
+	return c, nil
+}
+
+// matchCIDR checks if the address IP is in the subnet
+func matchCIDR(cidr *net.IPNet, address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+
+	return cidr.Contains(ip)
+}
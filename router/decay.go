@@ -0,0 +1,49 @@
+package router
+
+import (
+	"time"
+)
+
+// decay periodically reduces the metric of each route toward its baseline until the table is closed
+func (t *MemoryTable) decay(rate int, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.exit:
+			return
+		case <-ticker.C:
+			t.Lock()
+			t.decayMetrics(int64(rate))
+			t.Unlock()
+		}
+	}
+}
+
+// decayMetrics reduces the metric of each elevated route by rate. The decay is accumulated
+// until it reaches the decay delta or the route baseline. It must be called with the table lock held.
+func (t *MemoryTable) decayMetrics(rate int64) {
+	for _, rmap := range t.routes {
+		for sum, route := range rmap {
+			baseline, ok := t.baselines[sum]
+			if !ok || route.Metric <= baseline {
+				continue
+			}
+
+			decay := t.decayed[sum] + rate
+			if decay < t.opts.DecayDelta && route.Metric-decay > baseline {
+				t.decayed[sum] = decay
+				continue
+			}
+			delete(t.decayed, sum)
+
+			route.Metric -= decay
+			if route.Metric < baseline {
+				route.Metric = baseline
+			}
+
+			t.adjust(route, CauseHealth)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package router
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMetricDecay(t *testing.T) {
+	table := newTable(MetricDecay(10, 10*time.Millisecond), DispatchWorkers(1))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error watching table: %s", err)
+	}
+	defer w.Stop()
+
+	// penalize the route
+	route.Metric = 100
+
+	if err := table.Update(route); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+
+	metric := func() int64 {
+		routes, err := table.Query(QueryService(route.Service))
+		if err != nil {
+			t.Fatalf("error looking up routes: %s", err)
+		}
+		return routes[0].Metric
+	}
+
+	// the metric decreases over several intervals
+	time.Sleep(35 * time.Millisecond)
+
+	if m := metric(); m >= 100 || m <= 10 {
+		t.Errorf("expected partially decayed metric, found: %d", m)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for metric() != 10 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the metric recovers to the baseline and stays there
+	time.Sleep(30 * time.Millisecond)
+
+	if m := metric(); m != 10 {
+		t.Errorf("incorrect metric. Expected: %d, found: %d", 10, m)
+	}
+
+	// each decay step emits an update down to the baseline
+	var metrics []int64
+	for _, e := range collectEvents(w, 100*time.Millisecond) {
+		if e.Type == Update && e.Cause == CauseHealth {
+			metrics = append(metrics, e.Route.Metric)
+		}
+	}
+	if len(metrics) != 9 || metrics[0] != 90 || metrics[8] != 10 {
+		t.Errorf("expected decay updates from 90 to 10, found: %v", metrics)
+	}
+}
+
+func TestMetricDecayDelta(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	table := newTable(MetricDecayDelta(30), DispatchWorkers(1), TableClock(clock))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	route.Metric = 100
+	if err := table.Update(route); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error watching table: %s", err)
+	}
+	defer w.Stop()
+
+	updated := now
+	now = now.Add(time.Hour)
+
+	for i := 0; i < 12; i++ {
+		table.Lock()
+		table.decayMetrics(10)
+		table.Unlock()
+	}
+
+	var metrics []int64
+	for _, e := range collectEvents(w, 100*time.Millisecond) {
+		if e.Type == Update && e.Cause == CauseHealth {
+			metrics = append(metrics, e.Route.Metric)
+		}
+	}
+	if fmt.Sprint(metrics) != "[70 40 10]" {
+		t.Errorf("expected decay updates throttled to the delta, found: %v", metrics)
+	}
+
+	// decaying the metric does not refresh the route
+	routes, _ := table.List()
+	if len(routes) != 1 || routes[0].Metric != 10 || !routes[0].LastSeen.Equal(updated) {
+		t.Errorf("expected decayed route keeping its last seen time, found: %v", routes)
+	}
+}
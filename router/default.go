@@ -25,7 +25,7 @@ type router struct {
 	sync.RWMutex
 
 	running   bool
-	table     *table
+	table     *MemoryTable
 	options   Options
 	exit      chan bool
 	eventChan chan *Event
@@ -47,7 +47,7 @@ func newRouter(opts ...Option) Router {
 
 	return &router{
 		options:     options,
-		table:       newTable(),
+		table:       newTable(options.TableOptions...),
 		subscribers: make(map[string]chan *Advert),
 	}
 }
@@ -91,7 +91,12 @@ func (r *router) manageRoute(route Route, action string) error {
 			return fmt.Errorf("failed deleting route for service %s: %s", route.Service, err)
 		}
 	case "update":
-		if err := r.table.Update(route); err != nil {
+		err := r.table.Update(route)
+		// the route may not have been created yet
+		if err == ErrRouteNotFound {
+			err = r.table.Create(route)
+		}
+		if err != nil {
 			return fmt.Errorf("failed updating route for service %s: %s", route.Service, err)
 		}
 	default:
@@ -138,3 +138,24 @@ func TestRouterAdvertise(t *testing.T) {
 		t.Errorf("failed to stop router: %v", err)
 	}
 }
+
+func TestRouterTableOptions(t *testing.T) {
+	r := newRouter(Registry(memory.NewRegistry()), WithTableOptions(MaxRoutes(1)))
+
+	table, ok := r.Table().(*MemoryTable)
+	if !ok {
+		t.Fatalf("expected memory routing table, found: %T", r.Table())
+	}
+
+	_, route := testSetup()
+	for _, addr := range []string{"10.0.0.1", "10.0.0.2"} {
+		route.Address = addr
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	if routes, _ := table.List(); len(routes) != 1 {
+		t.Errorf("expected route limit to apply, found %d routes", len(routes))
+	}
+}
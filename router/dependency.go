@@ -0,0 +1,120 @@
+package router
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ErrDependencyCycle is returned when adding a dependency which would create a cycle
+var ErrDependencyCycle = errors.New("route dependency cycle")
+
+// lookupById returns the route with the given id. It must be called with the table lock held.
+func (t *MemoryTable) lookupById(id string) (Route, bool) {
+	service, ok := t.ids[id]
+	if !ok {
+		return Route{}, false
+	}
+
+	sum, err := strconv.ParseUint(id, 16, 64)
+	if err != nil {
+		return Route{}, false
+	}
+
+	route, ok := t.routes[service][sum]
+	return route, ok
+}
+
+// dependsOn checks if the route transitively depends on the other route.
+// It must be called with the table lock held.
+func (t *MemoryTable) dependsOn(id, other string) bool {
+	if id == other {
+		return true
+	}
+
+	for dep := range t.requires[id] {
+		if t.dependsOn(dep, other) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddDependency makes the route depend on the other route, both given by their ids.
+// When the route it depends on is deleted the dependent route is deleted too, or marked
+// Down if the table cascade mode is CascadeDown, emitting the events caused by
+// CauseDependency. The cascade continues to the routes depending on the dependent one.
+// It returns ErrRouteNotFound if either route does not exist and ErrDependencyCycle if
+// the other route already depends on the route.
+func (t *MemoryTable) AddDependency(dependentId, dependsOnId string) error {
+	t.Lock()
+	defer t.Unlock()
+
+	if _, ok := t.lookupById(dependentId); !ok {
+		return ErrRouteNotFound
+	}
+	if _, ok := t.lookupById(dependsOnId); !ok {
+		return ErrRouteNotFound
+	}
+
+	if t.dependsOn(dependsOnId, dependentId) {
+		return ErrDependencyCycle
+	}
+
+	if t.requires[dependentId] == nil {
+		t.requires[dependentId] = make(map[string]bool)
+	}
+	t.requires[dependentId][dependsOnId] = true
+
+	if t.dependents[dependsOnId] == nil {
+		t.dependents[dependsOnId] = make(map[string]bool)
+	}
+	t.dependents[dependsOnId][dependentId] = true
+
+	return nil
+}
+
+// cascade applies the deletion of the route to the routes depending on it and forgets
+// the route dependencies. It must be called with the table lock held.
+func (t *MemoryTable) cascade(id string) {
+	for dep := range t.requires[id] {
+		delete(t.dependents[dep], id)
+		if len(t.dependents[dep]) == 0 {
+			delete(t.dependents, dep)
+		}
+	}
+	delete(t.requires, id)
+
+	dependents := t.dependents[id]
+	delete(t.dependents, id)
+
+	for dep := range dependents {
+		route, ok := t.lookupById(dep)
+		if !ok {
+			continue
+		}
+
+		if t.opts.CascadeMode == CascadeDelete {
+			t.remove(route, CauseDependency)
+			continue
+		}
+
+		t.down(route)
+	}
+}
+
+// down marks the route and the routes depending on it Down. It must be called with the table lock held.
+func (t *MemoryTable) down(route Route) {
+	if route.Status == Down {
+		return
+	}
+
+	route.Status = Down
+	t.adjust(route, CauseDependency)
+
+	for dep := range t.dependents[route.Id] {
+		if r, ok := t.lookupById(dep); ok {
+			t.down(r)
+		}
+	}
+}
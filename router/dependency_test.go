@@ -0,0 +1,103 @@
+package router
+
+import (
+	"testing"
+)
+
+func dependencySetup(t *testing.T, table *MemoryTable) (upstream, proxy, edge Route) {
+	_, route := testSetup()
+
+	for _, address := range []string{"upstream", "proxy", "edge"} {
+		route.Address = address
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	routes, _ := table.List()
+	byAddress := make(map[string]Route)
+	for _, r := range routes {
+		byAddress[r.Address] = r
+	}
+	upstream, proxy, edge = byAddress["upstream"], byAddress["proxy"], byAddress["edge"]
+
+	// edge depends on proxy which depends on upstream
+	if err := table.AddDependency(proxy.Id, upstream.Id); err != nil {
+		t.Fatalf("error adding dependency: %s", err)
+	}
+	if err := table.AddDependency(edge.Id, proxy.Id); err != nil {
+		t.Fatalf("error adding dependency: %s", err)
+	}
+
+	return upstream, proxy, edge
+}
+
+func TestDependencyCascadeDelete(t *testing.T) {
+	table := newTable()
+	defer table.Close()
+
+	upstream, proxy, edge := dependencySetup(t, table)
+
+	if err := table.AddDependency(upstream.Id, edge.Id); err != ErrDependencyCycle {
+		t.Errorf("expected error: %s, found: %v", ErrDependencyCycle, err)
+	}
+	if err := table.AddDependency(upstream.Id, upstream.Id); err != ErrDependencyCycle {
+		t.Errorf("expected error: %s, found: %v", ErrDependencyCycle, err)
+	}
+	if err := table.AddDependency(upstream.Id, "missing"); err != ErrRouteNotFound {
+		t.Errorf("expected error: %s, found: %v", ErrRouteNotFound, err)
+	}
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	if err := table.Delete(upstream); err != nil {
+		t.Fatalf("error deleting route: %s", err)
+	}
+
+	if routes, _ := table.List(); len(routes) != 0 {
+		t.Errorf("expected dependent routes to be deleted, found: %d", len(routes))
+	}
+
+	deleted := make(map[string]Cause)
+	for len(deleted) < 3 {
+		e, err := w.Next()
+		if err != nil {
+			t.Fatalf("error receiving event: %s", err)
+		}
+		// the create events may be dispatched late
+		if e.Type != Delete {
+			continue
+		}
+		deleted[e.Route.Address] = e.Cause
+	}
+
+	if deleted[upstream.Address] != CauseDirect || deleted[proxy.Address] != CauseDependency || deleted[edge.Address] != CauseDependency {
+		t.Errorf("unexpected delete causes: %v", deleted)
+	}
+}
+
+func TestDependencyCascadeDown(t *testing.T) {
+	table := newTable(DependencyCascade(CascadeDown))
+	defer table.Close()
+
+	upstream, _, _ := dependencySetup(t, table)
+
+	if err := table.Delete(upstream); err != nil {
+		t.Fatalf("error deleting route: %s", err)
+	}
+
+	routes, _ := table.List()
+	if len(routes) != 2 {
+		t.Fatalf("expected dependent routes to be kept, found: %d", len(routes))
+	}
+
+	for _, r := range routes {
+		if r.Status != Down {
+			t.Errorf("expected route %s to be down, found: %s", r.Address, r.Status)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package router
+
+import (
+	"reflect"
+	"time"
+)
+
+// Diff returns the events which converge the old set of routes to the new one.
+// Routes missing from the old set are created, routes missing from the new set are
+// deleted and the routes which differ are updated. The events have no sequence number.
+func Diff(old, new []Route) []*Event {
+	return diff(old, new, hashRoute)
+}
+
+// hashRoute returns the route hash
+func hashRoute(r Route) uint64 {
+	return r.Hash()
+}
+
+// diff returns the events which converge the old set of routes to the new one
+// matching the routes by the given hash function
+func diff(old, new []Route, hash func(Route) uint64) []*Event {
+	oldRoutes := make(map[uint64]Route, len(old))
+	for _, route := range old {
+		oldRoutes[hash(route)] = route
+	}
+
+	newRoutes := make(map[uint64]Route, len(new))
+	for _, route := range new {
+		newRoutes[hash(route)] = route
+	}
+
+	now := time.Now()
+
+	var events []*Event
+
+	for sum, route := range oldRoutes {
+		if _, ok := newRoutes[sum]; !ok {
+			events = append(events, &Event{Type: Delete, Timestamp: now, Route: route})
+		}
+	}
+
+	for sum, route := range newRoutes {
+		prev, ok := oldRoutes[sum]
+		if !ok {
+			events = append(events, &Event{Type: Create, Timestamp: now, Route: route})
+			continue
+		}
+		if !reflect.DeepEqual(prev, route) {
+			events = append(events, &Event{Type: Update, Timestamp: now, Route: route})
+		}
+	}
+
+	return events
+}
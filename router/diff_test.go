@@ -0,0 +1,43 @@
+package router
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	_, route := testSetup()
+
+	var old []Route
+	for i := 0; i < 3; i++ {
+		route.Address = fmt.Sprintf("dest.addr-%d", i)
+		old = append(old, route)
+	}
+
+	changed := old[1]
+	changed.Metric = 100
+
+	added := route
+	added.Address = "dest.addr-3"
+
+	events := Diff(old, []Route{old[0], changed, added})
+	if len(events) != 3 {
+		t.Fatalf("incorrect number of events. Expected: %d, found: %d", 3, len(events))
+	}
+
+	expected := map[string]EventType{
+		old[2].Address:  Delete,
+		changed.Address: Update,
+		added.Address:   Create,
+	}
+
+	for _, event := range events {
+		if typ := expected[event.Route.Address]; typ != event.Type {
+			t.Errorf("incorrect event for route %s. Expected: %s, found: %s", event.Route.Address, typ, event.Type)
+		}
+	}
+
+	if events := Diff(old, old); len(events) != 0 {
+		t.Errorf("incorrect number of events. Expected: %d, found: %d", 0, len(events))
+	}
+}
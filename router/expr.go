@@ -0,0 +1,312 @@
+package router
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// expr is a parsed route filter expression
+type expr interface {
+	eval(Route) bool
+}
+
+// andExpr matches if both expressions match
+type andExpr struct {
+	left, right expr
+}
+
+func (e andExpr) eval(r Route) bool {
+	return e.left.eval(r) && e.right.eval(r)
+}
+
+// orExpr matches if either expression matches
+type orExpr struct {
+	left, right expr
+}
+
+func (e orExpr) eval(r Route) bool {
+	return e.left.eval(r) || e.right.eval(r)
+}
+
+// compareExpr compares a route field with a value
+type compareExpr struct {
+	field string
+	op    string
+	str   string
+	num   int64
+}
+
+// routeFields are the fields which can be used in expressions
+var routeFields = map[string]bool{
+	"service": false,
+	"address": false,
+	"gateway": false,
+	"network": false,
+	"router":  false,
+	"link":    false,
+	"metric":  true,
+}
+
+func (e compareExpr) eval(r Route) bool {
+	if e.field == "metric" {
+		switch e.op {
+		case "==":
+			return r.Metric == e.num
+		case "!=":
+			return r.Metric != e.num
+		case "<":
+			return r.Metric < e.num
+		case "<=":
+			return r.Metric <= e.num
+		case ">":
+			return r.Metric > e.num
+		case ">=":
+			return r.Metric >= e.num
+		}
+		return false
+	}
+
+	var val string
+	switch e.field {
+	case "service":
+		val = r.Service
+	case "address":
+		val = r.Address
+	case "gateway":
+		val = r.Gateway
+	case "network":
+		val = r.Network
+	case "router":
+		val = r.Router
+	case "link":
+		val = r.Link
+	}
+
+	switch e.op {
+	case "==":
+		return val == e.str
+	case "!=":
+		return val != e.str
+	case "startsWith":
+		return strings.HasPrefix(val, e.str)
+	case "contains":
+		return strings.Contains(val, e.str)
+	}
+
+	return false
+}
+
+// token is an expression token
+type token struct {
+	// kind is one of ident, string, number, op, ( and )
+	kind string
+	val  string
+}
+
+// tokenize splits the expression into tokens
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, token{kind: string(c)})
+			i++
+		case c == '"':
+			end := strings.IndexByte(s[i+1:], '"')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			tokens = append(tokens, token{kind: "string", val: s[i+1 : i+1+end]})
+			i += end + 2
+		case c == '-' || unicode.IsDigit(c):
+			j := i + 1
+			for j < len(s) && unicode.IsDigit(rune(s[j])) {
+				j++
+			}
+			tokens = append(tokens, token{kind: "number", val: s[i:j]})
+			i = j
+		case unicode.IsLetter(c):
+			j := i + 1
+			for j < len(s) && (unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			word := s[i:j]
+			if word == "startsWith" || word == "contains" {
+				tokens = append(tokens, token{kind: "op", val: word})
+			} else {
+				tokens = append(tokens, token{kind: "ident", val: word})
+			}
+			i = j
+		default:
+			var op string
+			for _, o := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">"} {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if len(op) == 0 {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, i)
+			}
+			tokens = append(tokens, token{kind: "op", val: op})
+			i += len(op)
+		}
+	}
+
+	return tokens, nil
+}
+
+// parser is a recursive descent expression parser
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *parser) next() *token {
+	t := p.peek()
+	if t != nil {
+		p.pos++
+	}
+	return t
+}
+
+// parseOr parses: and ("||" and)*
+func (p *parser) parseOr() (expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for t := p.peek(); t != nil && t.kind == "op" && t.val == "||"; t = p.peek() {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+
+	return left, nil
+}
+
+// parseAnd parses: term ("&&" term)*
+func (p *parser) parseAnd() (expr, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for t := p.peek(); t != nil && t.kind == "op" && t.val == "&&"; t = p.peek() {
+		p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+
+	return left, nil
+}
+
+// parseTerm parses: "(" or ")" | field op value
+func (p *parser) parseTerm() (expr, error) {
+	t := p.next()
+	if t == nil {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	if t.kind == "(" {
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t == nil || t.kind != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		return e, nil
+	}
+
+	if t.kind != "ident" {
+		return nil, fmt.Errorf("expected field, found %q", t.val)
+	}
+
+	numeric, ok := routeFields[t.val]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q", t.val)
+	}
+
+	e := compareExpr{field: t.val}
+
+	op := p.next()
+	if op == nil || op.kind != "op" || op.val == "&&" || op.val == "||" {
+		return nil, fmt.Errorf("expected operator after %q", e.field)
+	}
+	e.op = op.val
+
+	val := p.next()
+	if val == nil {
+		return nil, fmt.Errorf("expected value after %q", e.op)
+	}
+
+	if numeric {
+		if val.kind != "number" {
+			return nil, fmt.Errorf("field %q expects a number", e.field)
+		}
+		if e.op == "startsWith" || e.op == "contains" {
+			return nil, fmt.Errorf("operator %q not supported for field %q", e.op, e.field)
+		}
+		num, err := strconv.ParseInt(val.val, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		e.num = num
+		return e, nil
+	}
+
+	if val.kind != "string" {
+		return nil, fmt.Errorf("field %q expects a string", e.field)
+	}
+	switch e.op {
+	case "==", "!=", "startsWith", "contains":
+	default:
+		return nil, fmt.Errorf("operator %q not supported for field %q", e.op, e.field)
+	}
+	e.str = val.val
+
+	return e, nil
+}
+
+// parseExpr parses the route filter expression
+func parseExpr(s string) (expr, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: %v", err)
+	}
+
+	p := &parser{tokens: tokens}
+
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: %v", err)
+	}
+
+	if t := p.peek(); t != nil {
+		return nil, fmt.Errorf("invalid expression: unexpected %q", t.val)
+	}
+
+	return e, nil
+}
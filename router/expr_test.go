@@ -0,0 +1,103 @@
+package router
+
+import (
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	route := Route{
+		Service: "foo",
+		Address: "10.0.0.1:8080",
+		Gateway: "10.0.1.1",
+		Network: "net1",
+		Router:  "rtr1",
+		Link:    "local",
+		Metric:  10,
+	}
+
+	testData := []struct {
+		expr  string
+		match bool
+	}{
+		{`metric < 50`, true},
+		{`metric >= 50`, false},
+		{`metric == 10 && service == "foo"`, true},
+		{`metric < 50 && gateway startsWith "10.0."`, true},
+		{`metric < 50 && gateway startsWith "192.168."`, false},
+		{`network == "net2" || router contains "tr"`, true},
+		{`network == "net2" || metric > 100`, false},
+		{`(network == "net2" || link == "local") && address != "10.0.0.2:8080"`, true},
+		{`service == "bar" || service == "baz" && metric < 50`, false},
+		{`metric > -1`, true},
+	}
+
+	for _, d := range testData {
+		e, err := parseExpr(d.expr)
+		if err != nil {
+			t.Errorf("failed to parse %s: %v", d.expr, err)
+			continue
+		}
+		if match := e.eval(route); match != d.match {
+			t.Errorf("expression %s: expected match %v, found %v", d.expr, d.match, match)
+		}
+	}
+}
+
+func TestParseExprError(t *testing.T) {
+	testData := []string{
+		``,
+		`metric <`,
+		`foo == "bar"`,
+		`metric < "50"`,
+		`service < 10`,
+		`service startsWith "foo`,
+		`(metric < 50`,
+		`metric < 50 &&`,
+		`metric < 50 service == "foo"`,
+		`metric contains 5`,
+	}
+
+	for _, expr := range testData {
+		if _, err := parseExpr(expr); err == nil {
+			t.Errorf("expected parse error for expression: %s", expr)
+		}
+	}
+}
+
+func TestWatchExpr(t *testing.T) {
+	table, route := testSetup()
+
+	if _, err := table.Watch(WatchExpr(`metric <`)); err == nil {
+		t.Fatalf("expected error creating watcher with invalid expression")
+	}
+
+	w, err := table.Watch(WatchExpr(`metric < 50 && address startsWith "10.0."`))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	for _, r := range []struct {
+		address string
+		metric  int64
+	}{
+		{"10.1.0.1", 10},
+		{"10.0.0.1", 100},
+		{"10.0.0.2", 10},
+	} {
+		route.Address = r.address
+		route.Metric = r.metric
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	event, err := w.Next()
+	if err != nil {
+		t.Fatalf("error receiving event: %s", err)
+	}
+
+	if event.Route.Address != "10.0.0.2" {
+		t.Errorf("incorrect event route. Expected address: %s, found: %s", "10.0.0.2", event.Route.Address)
+	}
+}
@@ -0,0 +1,40 @@
+package router
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Fingerprint returns the canonical textual representation of the table routes,
+// one route per line sorted by route id. It excludes the route versions and the
+// timestamps, so the tables with the same routes have the same fingerprint
+// regardless of the order the routes were added in.
+func (t *MemoryTable) Fingerprint() string {
+	t.RLock()
+	routes := t.list()
+	t.RUnlock()
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Id < routes[j].Id
+	})
+
+	var b strings.Builder
+	for _, r := range routes {
+		fmt.Fprintf(&b, "%s service=%s address=%s gateway=%s network=%s router=%s link=%s metric=%d status=%s class=%s weight=%d",
+			r.Id, r.Service, r.Address, r.Gateway, r.Network, r.Router, r.Link, r.Metric, r.Status, r.Class, r.Weight)
+
+		keys := make([]string, 0, len(r.Metadata))
+		for k := range r.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			fmt.Fprintf(&b, " %s=%s", k, r.Metadata[k])
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package router
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	_, route := testSetup()
+
+	routes := make([]Route, 4)
+	for i := range routes {
+		routes[i] = route
+		routes[i].Address = fmt.Sprintf("10.0.0.%d", i)
+		routes[i].Metadata = map[string]string{"zone": "a", "group": "blue"}
+	}
+
+	first := newTable()
+	for _, r := range routes {
+		if err := first.Create(r); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	// build the same routes in a different order and through updates
+	second := newTable()
+	for i := len(routes) - 1; i >= 0; i-- {
+		r := routes[i]
+		r.Metric = 100
+		if err := second.Create(r); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+		if err := second.Update(routes[i]); err != nil {
+			t.Fatalf("error updating route: %s", err)
+		}
+	}
+
+	if first.Fingerprint() != second.Fingerprint() {
+		t.Errorf("expected identical fingerprints, found:\n%s\nand:\n%s", first.Fingerprint(), second.Fingerprint())
+	}
+
+	if n := strings.Count(first.Fingerprint(), "\n"); n != len(routes) {
+		t.Errorf("expected %d fingerprint lines, found: %d", len(routes), n)
+	}
+
+	if err := second.Delete(routes[0]); err != nil {
+		t.Fatalf("error deleting route: %s", err)
+	}
+
+	if first.Fingerprint() == second.Fingerprint() {
+		t.Errorf("expected different fingerprints")
+	}
+}
@@ -0,0 +1,109 @@
+package router
+
+// copyMetadata returns a copy of the route metadata
+func copyMetadata(md map[string]string) map[string]string {
+	cp := make(map[string]string, len(md))
+	for k, v := range md {
+		cp[k] = v
+	}
+	return cp
+}
+
+// mergeMetadata merges the route metadata into the metadata of the stored route
+// when the table merges metadata. Keys with empty values are deleted.
+// It must be called with the table lock held.
+func (t *MemoryTable) mergeMetadata(r Route) Route {
+	if t.opts.MetadataMode != MetadataMerge {
+		return r
+	}
+
+	stored, ok := t.routes[r.Service][t.hash(r)]
+	if !ok {
+		return r
+	}
+
+	md := copyMetadata(stored.Metadata)
+	for k, v := range r.Metadata {
+		if len(v) == 0 {
+			delete(md, k)
+			continue
+		}
+		md[k] = v
+	}
+	r.Metadata = md
+
+	return r
+}
+
+// groupRoutes returns the routes of the group. It must be called with the table lock held.
+func (t *MemoryTable) groupRoutes(group string) []Route {
+	var routes []Route
+	for _, rmap := range t.routes {
+		for _, route := range rmap {
+			if route.Metadata[GroupMetadataKey] == group {
+				routes = append(routes, route)
+			}
+		}
+	}
+	return routes
+}
+
+// Tag adds all the routes to the service address into the group.
+// The group is stored in the route metadata; a route belongs to a single group.
+// Update event is emitted for each tagged route. It returns ErrRouteNotFound if there are no routes to the service address.
+func (t *MemoryTable) Tag(service, address, group string) error {
+	t.Lock()
+	defer t.Unlock()
+
+	var found bool
+	for _, route := range t.routes[t.resolve(service)] {
+		if route.Address != address {
+			continue
+		}
+		found = true
+
+		route.Metadata = copyMetadata(route.Metadata)
+		route.Metadata[GroupMetadataKey] = group
+		t.adjust(route, CauseDirect)
+	}
+
+	if !found {
+		return ErrRouteNotFound
+	}
+
+	return nil
+}
+
+// DeleteGroup deletes all the routes of the group, emitting Delete event for each of them.
+// It returns the number of deleted routes.
+func (t *MemoryTable) DeleteGroup(group string) int {
+	t.Lock()
+	defer t.Unlock()
+
+	routes := t.groupRoutes(group)
+	for _, route := range routes {
+		t.remove(route, CauseDirect)
+	}
+
+	return len(routes)
+}
+
+// DrainGroup marks all the routes of the group as Draining, emitting Update event for each of them.
+// It returns the number of routes which have been drained.
+func (t *MemoryTable) DrainGroup(group string) int {
+	t.Lock()
+	defer t.Unlock()
+
+	var drained int
+	for _, route := range t.groupRoutes(group) {
+		if route.Status == Draining {
+			continue
+		}
+
+		route.Status = Draining
+		t.adjust(route, CauseDirect)
+		drained++
+	}
+
+	return drained
+}
@@ -0,0 +1,91 @@
+package router
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestGroup(t *testing.T) {
+	table, route := testSetup()
+
+	for i := 0; i < 4; i++ {
+		route.Address = fmt.Sprintf("dest.addr-%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	// wait for the previous events to be dispatched
+	collectEvents(w, 50*time.Millisecond)
+
+	// tag half of the routes as canary
+	for i := 0; i < 2; i++ {
+		if err := table.Tag(route.Service, fmt.Sprintf("dest.addr-%d", i), "canary"); err != nil {
+			t.Fatalf("error tagging route: %s", err)
+		}
+	}
+
+	if err := table.Tag(route.Service, "missing.addr", "canary"); err != ErrRouteNotFound {
+		t.Errorf("expected error: %s, found: %v", ErrRouteNotFound, err)
+	}
+
+	tagged := collectEvents(w, 100*time.Millisecond)
+	if len(tagged) != 2 {
+		t.Fatalf("incorrect number of tag events. Expected: %d, found: %d", 2, len(tagged))
+	}
+
+	for _, event := range tagged {
+		if event.Type != Update || event.Route.Metadata[GroupMetadataKey] != "canary" {
+			t.Errorf("unexpected tag event: %s", event)
+		}
+	}
+
+	if n := table.DrainGroup("canary"); n != 2 {
+		t.Fatalf("incorrect number of drained routes. Expected: %d, found: %d", 2, n)
+	}
+
+	events := collectEvents(w, 100*time.Millisecond)
+	if len(events) != 2 {
+		t.Fatalf("incorrect number of events. Expected: %d, found: %d", 2, len(events))
+	}
+
+	for _, event := range events {
+		if event.Type != Update || event.Route.Status != Draining {
+			t.Errorf("unexpected event %s for route with status %s", event.Type, event.Route.Status)
+		}
+		if event.Route.Metadata[GroupMetadataKey] != "canary" {
+			t.Errorf("incorrect route group: %s", event.Route.Metadata[GroupMetadataKey])
+		}
+	}
+
+	// draining again is a noop
+	if n := table.DrainGroup("canary"); n != 0 {
+		t.Errorf("incorrect number of drained routes. Expected: %d, found: %d", 0, n)
+	}
+
+	if n := table.DeleteGroup("canary"); n != 2 {
+		t.Fatalf("incorrect number of deleted routes. Expected: %d, found: %d", 2, n)
+	}
+
+	routes, err := table.List()
+	if err != nil {
+		t.Fatalf("error listing routes: %s", err)
+	}
+
+	if len(routes) != 2 {
+		t.Fatalf("incorrect number of routes. Expected: %d, found: %d", 2, len(routes))
+	}
+
+	for _, r := range routes {
+		if r.Status != Healthy || len(r.Metadata) != 0 {
+			t.Errorf("ungrouped route modified: %+v", r)
+		}
+	}
+}
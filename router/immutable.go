@@ -0,0 +1,143 @@
+package router
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/micro/go-micro/v2/logger"
+)
+
+// immutableFieldNames are the lower case names of the route fields which can be immutable.
+// Version and LastSeen are managed by the table.
+var immutableFieldNames = map[string]bool{
+	"id":         true,
+	"service":    true,
+	"address":    true,
+	"gateway":    true,
+	"network":    true,
+	"router":     true,
+	"link":       true,
+	"metric":     true,
+	"status":     true,
+	"class":      true,
+	"weight":     true,
+	"metadata":   true,
+	"activateat": true,
+}
+
+// knownFields returns the lower case names of the fields, logging the unknown ones
+func (t *MemoryTable) knownFields(fields []string) []string {
+	var known []string
+	for _, f := range fields {
+		name := strings.ToLower(f)
+		if !immutableFieldNames[name] {
+			t.logf(logger.ErrorLevel, "Router ignoring unknown immutable route field %s", f)
+			continue
+		}
+		known = append(known, name)
+	}
+	return known
+}
+
+// fieldChanged returns true if the updated route changes the stored route field
+func fieldChanged(field string, stored, r Route) bool {
+	switch field {
+	case "id":
+		// the route id is assigned by the table so an empty id keeps it
+		return len(r.Id) > 0 && r.Id != stored.Id
+	case "service":
+		return r.Service != stored.Service
+	case "address":
+		return r.Address != stored.Address
+	case "gateway":
+		return r.Gateway != stored.Gateway
+	case "network":
+		return r.Network != stored.Network
+	case "router":
+		return r.Router != stored.Router
+	case "link":
+		return r.Link != stored.Link
+	case "metric":
+		return r.Metric != stored.Metric
+	case "status":
+		return r.Status != stored.Status
+	case "class":
+		return r.Class != stored.Class
+	case "weight":
+		return r.Weight != stored.Weight
+	case "metadata":
+		return len(r.Metadata)+len(stored.Metadata) > 0 && !reflect.DeepEqual(r.Metadata, stored.Metadata)
+	case "activateat":
+		return !r.ActivateAt.Equal(stored.ActivateAt)
+	}
+	return false
+}
+
+// updatedRoutes returns the stored or scheduled routes the route update applies to. As the
+// route hash may cover the immutable fields, the route which is not found by its hash is
+// looked up by the stable route id or, without it, by the route service and address.
+// It must be called with the table lock held.
+func (t *MemoryTable) updatedRoutes(r Route) []Route {
+	sum := t.hash(r)
+	if stored, ok := t.routes[r.Service][sum]; ok {
+		return []Route{stored}
+	}
+	if stored, ok := t.pending[sum]; ok {
+		return []Route{stored}
+	}
+
+	if service, ok := t.ids[r.Id]; ok && len(r.Id) > 0 {
+		for _, stored := range t.routes[service] {
+			if stored.Id == r.Id {
+				return []Route{stored}
+			}
+		}
+	}
+
+	var routes []Route
+	for _, stored := range t.routes[r.Service] {
+		if stored.Address == r.Address {
+			routes = append(routes, stored)
+		}
+	}
+	for _, stored := range t.pending {
+		if stored.Service == r.Service && stored.Address == r.Address {
+			routes = append(routes, stored)
+		}
+	}
+
+	return routes
+}
+
+// checkImmutable returns ErrImmutableField if the route update changes an immutable field
+// of the stored or scheduled route. Of several routes to the service address the update
+// is accepted if it keeps the immutable fields of any of them. It must be called with
+// the table lock held.
+func (t *MemoryTable) checkImmutable(r Route) error {
+	if len(t.opts.ImmutableFields) == 0 {
+		return nil
+	}
+
+	routes := t.updatedRoutes(r)
+	if len(routes) == 0 {
+		return nil
+	}
+
+	for _, stored := range routes {
+		if !immutableChanged(t.opts.ImmutableFields, stored, r) {
+			return nil
+		}
+	}
+
+	return ErrImmutableField
+}
+
+// immutableChanged returns true if the updated route changes any of the stored route fields
+func immutableChanged(fields []string, stored, r Route) bool {
+	for _, field := range fields {
+		if fieldChanged(field, stored, r) {
+			return true
+		}
+	}
+	return false
+}
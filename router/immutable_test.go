@@ -0,0 +1,116 @@
+package router
+
+import (
+	"testing"
+)
+
+func TestImmutableFields(t *testing.T) {
+	// the network is left out of the route identity so that it can be updated
+	hash := func(r Route) uint64 {
+		return (&Route{Service: r.Service, Address: r.Address}).Hash()
+	}
+
+	table := newTable(ImmutableFields("ID", "Network"), TableHasher(hash))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	changed := route
+	changed.Network = "other.network"
+	if err := table.Update(changed); err != ErrImmutableField {
+		t.Errorf("expected error: %s, found: %v", ErrImmutableField, err)
+	}
+	if _, err := table.Upsert(changed); err != ErrImmutableField {
+		t.Errorf("expected error: %s, found: %v", ErrImmutableField, err)
+	}
+
+	changed = route
+	changed.Id = "other.id"
+	if err := table.Update(changed); err != ErrImmutableField {
+		t.Errorf("expected error: %s, found: %v", ErrImmutableField, err)
+	}
+
+	routes, _ := table.List()
+	if len(routes) != 1 || routes[0].Network != route.Network || routes[0].Version != 1 {
+		t.Fatalf("expected rejected updates to keep the route, found: %v", routes)
+	}
+
+	// the mutable fields and the assigned route id can be updated
+	changed = routes[0]
+	changed.Metric = 100
+	if err := table.Update(changed); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+
+	routes, _ = table.List()
+	if len(routes) != 1 || routes[0].Metric != 100 {
+		t.Errorf("expected updated metric, found: %v", routes)
+	}
+}
+
+func TestImmutableIdentityFields(t *testing.T) {
+	table := newTable(ImmutableFields("Service", "Network"))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	// the network is covered by the default hash so the route is found by its address
+	changed := route
+	changed.Network = "other.network"
+	if err := table.Update(changed); err != ErrImmutableField {
+		t.Errorf("expected error: %s, found: %v", ErrImmutableField, err)
+	}
+	if _, err := table.Upsert(changed); err != ErrImmutableField {
+		t.Errorf("expected error: %s, found: %v", ErrImmutableField, err)
+	}
+	if err := table.CompareAndUpdate(1, changed); err != ErrImmutableField {
+		t.Errorf("expected error: %s, found: %v", ErrImmutableField, err)
+	}
+
+	// the route moved to another service is found by its id
+	routes, _ := table.List()
+	changed = routes[0]
+	changed.Service = "other.service"
+	if err := table.Update(changed); err != ErrImmutableField {
+		t.Errorf("expected error: %s, found: %v", ErrImmutableField, err)
+	}
+
+	routes, _ = table.List()
+	if len(routes) != 1 || routes[0].Network != route.Network || routes[0].Version != 1 {
+		t.Fatalf("expected rejected updates to keep the route, found: %v", routes)
+	}
+
+	// the routes to another address are not affected
+	changed = route
+	changed.Address = "other.address"
+	changed.Network = "other.network"
+	if _, err := table.Upsert(changed); err != nil {
+		t.Errorf("error adding route: %s", err)
+	}
+}
+
+func TestUnknownImmutableFields(t *testing.T) {
+	log := new(testLogger)
+
+	table := newTable(ImmutableFields("Metrics", "LastSeen", "Metric"), TableLogger(log))
+	defer table.Close()
+
+	if fields := table.opts.ImmutableFields; len(fields) != 1 || fields[0] != "metric" {
+		t.Errorf("expected only the known fields to be immutable, found: %v", fields)
+	}
+
+	log.Lock()
+	defer log.Unlock()
+
+	if len(log.messages) != 2 {
+		t.Errorf("expected the unknown fields to be logged, found: %v", log.messages)
+	}
+}
@@ -0,0 +1,71 @@
+package router
+
+// stringPool interns strings so that equal strings share the same memory.
+// Strings are reference counted and dropped from the pool once unused.
+type stringPool struct {
+	strings map[string]*pooledString
+}
+
+type pooledString struct {
+	s    string
+	refs int
+}
+
+func newStringPool() *stringPool {
+	return &stringPool{strings: make(map[string]*pooledString)}
+}
+
+// intern returns the pooled copy of the string
+func (p *stringPool) intern(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+
+	ps, ok := p.strings[s]
+	if !ok {
+		ps = &pooledString{s: s}
+		p.strings[s] = ps
+	}
+	ps.refs++
+
+	return ps.s
+}
+
+// release drops the reference to the pooled string
+func (p *stringPool) release(s string) {
+	ps, ok := p.strings[s]
+	if !ok {
+		return
+	}
+
+	ps.refs--
+	if ps.refs <= 0 {
+		delete(p.strings, s)
+	}
+}
+
+// internRoute interns the route gateway, network and router.
+// It must be called with the table lock held.
+func (t *MemoryTable) internRoute(r Route) Route {
+	if t.pool == nil {
+		return r
+	}
+
+	r.Gateway = t.pool.intern(r.Gateway)
+	r.Network = t.pool.intern(r.Network)
+	r.Router = t.pool.intern(r.Router)
+
+	return r
+}
+
+// releaseRoute releases the interned route gateway, network and router.
+// It must be called with the table lock held.
+func (t *MemoryTable) releaseRoute(r Route) {
+	if t.pool == nil {
+		return
+	}
+
+	t.pool.release(r.Gateway)
+	t.pool.release(r.Network)
+	t.pool.release(r.Router)
+}
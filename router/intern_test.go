@@ -0,0 +1,91 @@
+package router
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestStringInterning(t *testing.T) {
+	table := newTable(TableStringInterning(true))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	for i := 0; i < 10; i++ {
+		route.Address = fmt.Sprintf("10.0.0.%d", i)
+		// build distinct copies of the same gateway
+		route.Gateway = strings.Repeat("g", 2) + "w"
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	routes, err := table.Query(QueryService(route.Service), QueryGateway("ggw"))
+	if err != nil {
+		t.Fatalf("error looking up routes: %s", err)
+	}
+
+	if len(routes) != 10 {
+		t.Fatalf("expected 10 routes, found: %d", len(routes))
+	}
+
+	if ps := table.pool.strings["ggw"]; ps == nil || ps.refs != 10 {
+		t.Fatalf("expected the gateway to be interned by 10 routes, found: %+v", ps)
+	}
+
+	// updates do not leak references
+	route.Metric = 100
+	if err := table.Update(route); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+
+	if ps := table.pool.strings["ggw"]; ps.refs != 10 {
+		t.Errorf("expected 10 references, found: %d", ps.refs)
+	}
+
+	for _, r := range routes {
+		if err := table.Delete(r); err != nil {
+			t.Fatalf("error deleting route: %s", err)
+		}
+	}
+
+	// unused strings are dropped from the pool
+	if n := len(table.pool.strings); n != 0 {
+		t.Errorf("expected empty string pool, found %d strings", n)
+	}
+}
+
+func benchmarkStringInterning(b *testing.B, interning bool) {
+	const routes = 10000
+
+	var stats runtime.MemStats
+
+	for i := 0; i < b.N; i++ {
+		runtime.GC()
+		runtime.ReadMemStats(&stats)
+		before := stats.HeapAlloc
+
+		table := newTable(TableStringInterning(interning))
+		for j := 0; j < routes; j++ {
+			table.Create(Route{
+				Service: "dest.svc",
+				Address: fmt.Sprintf("10.0.%d.%d", j/256, j%256),
+				Gateway: fmt.Sprintf("gateway-%d.example.com", j%8),
+				Network: fmt.Sprintf("network-%d", j%4),
+				Router:  fmt.Sprintf("router-%d", j%4),
+			})
+		}
+
+		runtime.GC()
+		runtime.ReadMemStats(&stats)
+		b.ReportMetric(float64(stats.HeapAlloc-before)/routes, "B/route")
+
+		runtime.KeepAlive(table)
+		table.Close()
+	}
+}
+
+func BenchmarkStringInterningOff(b *testing.B) { benchmarkStringInterning(b, false) }
+func BenchmarkStringInterningOn(b *testing.B)  { benchmarkStringInterning(b, true) }
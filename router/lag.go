@@ -0,0 +1,53 @@
+package router
+
+import (
+	"time"
+)
+
+// LagWatcher is a watcher reporting how far behind the table its consumer is
+type LagWatcher interface {
+	Watcher
+	// Lag returns the number of the queued events and the age of the consumer view
+	Lag() (events int, age time.Duration)
+}
+
+// emitted returns the timestamp of the last emitted event
+func (t *MemoryTable) emitted() time.Time {
+	t.RLock()
+	defer t.RUnlock()
+
+	return t.stamp
+}
+
+// advanceLocked records the event returned by the watcher. It must be called with the watcher lock held.
+func (w *tableWatcher) advanceLocked(e *Event) {
+	if e.Seq > w.position {
+		w.position = e.Seq
+	}
+	if e.Timestamp.After(w.seen) {
+		w.seen = e.Timestamp
+	}
+}
+
+// Lag returns the number of the events queued for the watcher which its consumer has not received
+// yet and how much older the newest event the consumer has received is than the last event emitted
+// by the table, or the watcher creation time if the consumer has received no event. Both are zero
+// once the consumer has caught up. The dropped events are not counted. Only the events returned
+// by Next and NextAck are tracked, the events received from the watcher channel are not.
+func (w *tableWatcher) Lag() (events int, age time.Duration) {
+	events = w.Len()
+	if events == 0 {
+		return 0, 0
+	}
+
+	emitted := w.emitted()
+
+	w.RLock()
+	defer w.RUnlock()
+
+	if age = emitted.Sub(w.seen); age < 0 {
+		age = 0
+	}
+
+	return events, age
+}
@@ -0,0 +1,79 @@
+package router
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestWatcherLag(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	table := newTable(DispatchWorkers(1), TableClock(clock))
+	defer table.Close()
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error watching table: %s", err)
+	}
+	defer w.Stop()
+
+	lw, ok := w.(LagWatcher)
+	if !ok {
+		t.Fatalf("expected watcher to report its lag")
+	}
+
+	if events, age := lw.Lag(); events != 0 || age != 0 {
+		t.Errorf("expected no lag, found: %d events, %s", events, age)
+	}
+
+	_, route := testSetup()
+
+	// waits for the dispatched events to be queued
+	queued := func(n int) {
+		deadline := time.Now().Add(time.Second)
+		for w.Len() < n && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	var lastEvents int
+	var lastAge time.Duration
+
+	for i := 1; i <= 3; i++ {
+		now = now.Add(time.Second)
+		route.Address = fmt.Sprintf("10.0.0.%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+		queued(i)
+
+		events, age := lw.Lag()
+		if events <= lastEvents || age <= lastAge {
+			t.Fatalf("expected lag to grow beyond %d events, %s, found: %d events, %s", lastEvents, lastAge, events, age)
+		}
+		lastEvents, lastAge = events, age
+	}
+
+	if lastEvents != 3 || lastAge != 3*time.Second {
+		t.Errorf("expected lag of 3 events, 3s, found: %d events, %s", lastEvents, lastAge)
+	}
+
+	// reading an event reduces the lag
+	if _, err := w.Next(); err != nil {
+		t.Fatalf("error reading event: %s", err)
+	}
+	if events, age := lw.Lag(); events != 2 || age != 2*time.Second {
+		t.Errorf("expected lag of 2 events, 2s, found: %d events, %s", events, age)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := w.Next(); err != nil {
+			t.Fatalf("error reading event: %s", err)
+		}
+	}
+	if events, age := lw.Lag(); events != 0 || age != 0 {
+		t.Errorf("expected no lag once caught up, found: %d events, %s", events, age)
+	}
+}
@@ -0,0 +1,106 @@
+package router
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrLeaseHeld is returned when the route lease is held by another owner
+	ErrLeaseHeld = errors.New("route lease held by another owner")
+	// ErrLeaseNotFound is returned when renewing a lease which does not exist or has expired
+	ErrLeaseNotFound = errors.New("route lease not found")
+)
+
+// leaseKey identifies the leased routes
+type leaseKey struct {
+	service string
+	address string
+}
+
+// lease is an exclusive right to modify the routes to a service address
+type lease struct {
+	owner  string
+	expiry time.Time
+}
+
+// activeLease returns the unexpired lease of the service address, deleting the expired one.
+// It must be called with the table lock held.
+func (t *MemoryTable) activeLease(key leaseKey) (lease, bool) {
+	l, ok := t.leases[key]
+	if !ok {
+		return lease{}, false
+	}
+
+	if !t.opts.Clock().Before(l.expiry) {
+		delete(t.leases, key)
+		return lease{}, false
+	}
+
+	return l, true
+}
+
+// checkLease returns ErrLeaseHeld if the route is leased by an owner other than the route router.
+// It must be called with the table lock held.
+func (t *MemoryTable) checkLease(r Route) error {
+	if len(t.leases) == 0 {
+		return nil
+	}
+
+	l, ok := t.activeLease(leaseKey{r.Service, r.Address})
+	if ok && l.owner != r.Router {
+		return ErrLeaseHeld
+	}
+
+	return nil
+}
+
+// leased returns true if the service address is leased. It must be called with the table lock held.
+func (t *MemoryTable) leased(service, address string) bool {
+	_, ok := t.activeLease(leaseKey{service, address})
+	return ok
+}
+
+// AcquireLease grants the owner the exclusive right to write the routes to the service address
+// for the ttl. The owner is the id of the router the routes originate from, while the lease is
+// held the creates, updates, deletes and imports of the routes of the other routers fail with
+// ErrLeaseHeld, Set leaves them as they are and the address can't be migrated. The lease expires
+// unless it is renewed, after which it can be acquired by another owner. Acquiring the lease
+// already held by the owner renews it.
+func (t *MemoryTable) AcquireLease(service, address, owner string, ttl time.Duration) error {
+	t.Lock()
+	defer t.Unlock()
+
+	key := leaseKey{t.resolve(service), address}
+
+	if l, ok := t.activeLease(key); ok && l.owner != owner {
+		return ErrLeaseHeld
+	}
+
+	t.leases[key] = lease{owner: owner, expiry: t.opts.Clock().Add(ttl)}
+
+	return nil
+}
+
+// RenewLease extends the lease held by the owner for the ttl from now. It returns
+// ErrLeaseHeld if the lease is held by another owner and ErrLeaseNotFound if the
+// lease does not exist or has expired.
+func (t *MemoryTable) RenewLease(service, address, owner string, ttl time.Duration) error {
+	t.Lock()
+	defer t.Unlock()
+
+	key := leaseKey{t.resolve(service), address}
+
+	l, ok := t.activeLease(key)
+	if !ok {
+		return ErrLeaseNotFound
+	}
+	if l.owner != owner {
+		return ErrLeaseHeld
+	}
+
+	l.expiry = t.opts.Clock().Add(ttl)
+	t.leases[key] = l
+
+	return nil
+}
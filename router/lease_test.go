@@ -0,0 +1,152 @@
+package router
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestLease(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	table := newTable(TableClock(clock))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	a, b := route, route
+	a.Router = "router-a"
+	b.Router = "router-b"
+
+	for _, r := range []Route{a, b} {
+		if err := table.Create(r); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	if err := table.AcquireLease(route.Service, route.Address, a.Router, time.Minute); err != nil {
+		t.Fatalf("error acquiring lease: %s", err)
+	}
+
+	// the lease is held by router a
+	if err := table.AcquireLease(route.Service, route.Address, b.Router, time.Minute); err != ErrLeaseHeld {
+		t.Errorf("expected error: %s, found: %v", ErrLeaseHeld, err)
+	}
+	if err := table.RenewLease(route.Service, route.Address, b.Router, time.Minute); err != ErrLeaseHeld {
+		t.Errorf("expected error: %s, found: %v", ErrLeaseHeld, err)
+	}
+
+	b.Metric = 50
+	if err := table.Update(b); err != ErrLeaseHeld {
+		t.Errorf("expected error: %s, found: %v", ErrLeaseHeld, err)
+	}
+	if err := table.Delete(b); err != ErrLeaseHeld {
+		t.Errorf("expected error: %s, found: %v", ErrLeaseHeld, err)
+	}
+
+	a.Metric = 50
+	if err := table.Update(a); err != nil {
+		t.Errorf("error updating route of the lease owner: %s", err)
+	}
+
+	// the renewed lease outlives its original ttl
+	now = now.Add(45 * time.Second)
+	if err := table.RenewLease(route.Service, route.Address, a.Router, time.Minute); err != nil {
+		t.Fatalf("error renewing lease: %s", err)
+	}
+
+	now = now.Add(45 * time.Second)
+	if err := table.Delete(b); err != ErrLeaseHeld {
+		t.Errorf("expected error: %s, found: %v", ErrLeaseHeld, err)
+	}
+
+	// the expired lease is handed off to router b
+	now = now.Add(time.Minute)
+	if err := table.RenewLease(route.Service, route.Address, a.Router, time.Minute); err != ErrLeaseNotFound {
+		t.Errorf("expected error: %s, found: %v", ErrLeaseNotFound, err)
+	}
+	if err := table.AcquireLease(route.Service, route.Address, b.Router, time.Minute); err != nil {
+		t.Fatalf("error acquiring expired lease: %s", err)
+	}
+
+	if err := table.Delete(a); err != ErrLeaseHeld {
+		t.Errorf("expected error: %s, found: %v", ErrLeaseHeld, err)
+	}
+	if err := table.Delete(b); err != nil {
+		t.Errorf("error deleting route of the lease owner: %s", err)
+	}
+}
+
+func TestLeaseWritePaths(t *testing.T) {
+	table, route := testSetup()
+	defer table.Close()
+
+	owned, leased, other := route, route, route
+	owned.Address = "owned.addr"
+	owned.Router = "router-a"
+	leased.Address = owned.Address
+	leased.Router = "router-b"
+	other.Address = "other.addr"
+	other.Router = "router-b"
+
+	for _, r := range []Route{owned, leased, other} {
+		if err := table.Create(r); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	if err := table.AcquireLease(route.Service, owned.Address, owned.Router, time.Minute); err != nil {
+		t.Fatalf("error acquiring lease: %s", err)
+	}
+
+	// the routes of the other routers to the leased address can't be written
+	if err := table.Delete(leased); err != ErrLeaseHeld {
+		t.Errorf("expected error: %s, found: %v", ErrLeaseHeld, err)
+	}
+
+	leased.Metric = 50
+	if err := table.Create(leased); err != ErrLeaseHeld {
+		t.Errorf("expected error: %s, found: %v", ErrLeaseHeld, err)
+	}
+	if _, err := table.Upsert(leased); err != ErrLeaseHeld {
+		t.Errorf("expected error: %s, found: %v", ErrLeaseHeld, err)
+	}
+
+	created := leased
+	created.Gateway = "other.gw"
+	if _, err := table.Upsert(created); err != ErrLeaseHeld {
+		t.Errorf("expected error: %s, found: %v", ErrLeaseHeld, err)
+	}
+
+	source := newTable()
+	defer source.Close()
+
+	if err := source.Create(leased); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := source.Export(&buf); err != nil {
+		t.Fatalf("error exporting routes: %s", err)
+	}
+	if _, err := table.Import(&buf); err != ErrLeaseHeld {
+		t.Errorf("expected error: %s, found: %v", ErrLeaseHeld, err)
+	}
+
+	// Set keeps the leased route of the other router while the other routes converge
+	table.Set([]Route{created})
+
+	routes, _ := table.List()
+	if len(routes) != 1 || routes[0].Router != leased.Router || routes[0].Metric != route.Metric {
+		t.Errorf("expected only the unchanged leased route to be kept, found: %v", routes)
+	}
+
+	// the leased address can't be migrated
+	if err := table.Create(other); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+	if _, err := table.Migrate(route.Service, owned.Address, other.Address, 2, time.Hour); err != ErrLeaseHeld {
+		t.Errorf("expected error: %s, found: %v", ErrLeaseHeld, err)
+	}
+}
@@ -0,0 +1,181 @@
+package router
+
+import (
+	"sync"
+	"time"
+)
+
+// Migration shifts the traffic of a service from one address to another
+type Migration struct {
+	sync.Mutex
+	t       *MemoryTable
+	service string
+	from    string
+	to      string
+	steps   int
+	step    int
+	stopped bool
+	done    chan struct{}
+}
+
+// Migrate gradually shifts the traffic of the service from the routes to fromAddr to the
+// routes to toAddr. The route weights start at 100 for the old routes and 0 for the new
+// ones and every interval they are shifted by 100/steps percent, emitting Update events
+// caused by CauseMigration. Once the new routes carry all the traffic the old routes are
+// deleted. It returns ErrRouteNotFound if there are no routes to either address,
+// ErrLeaseHeld if either address is leased, ErrInvalidInterval if the interval is not
+// positive and ErrTableClosed if the table is closed.
+//
+// The migration runs in the background until it completes, it is aborted or the table is
+// closed. Aborting the migration stops the shift leaving the route weights as they are.
+// As the migration is not made by the lease owner, leasing either address aborts it.
+func (t *MemoryTable) Migrate(service, fromAddr, toAddr string, steps int, interval time.Duration) (*Migration, error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+
+	if steps < 1 {
+		steps = 1
+	}
+
+	select {
+	case <-t.exit:
+		return nil, ErrTableClosed
+	default:
+	}
+
+	m := &Migration{
+		t:       t,
+		service: t.resolve(service),
+		from:    fromAddr,
+		to:      toAddr,
+		steps:   steps,
+		done:    make(chan struct{}),
+	}
+
+	t.Lock()
+	if len(t.addressRoutes(m.service, fromAddr)) == 0 || len(t.addressRoutes(m.service, toAddr)) == 0 {
+		t.Unlock()
+		return nil, ErrRouteNotFound
+	}
+	if t.leased(m.service, fromAddr) || t.leased(m.service, toAddr) {
+		t.Unlock()
+		return nil, ErrLeaseHeld
+	}
+	m.shift(0)
+	t.Unlock()
+
+	t.run(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if m.next() {
+					return
+				}
+			case <-m.done:
+				return
+			case <-t.exit:
+				m.Abort()
+				return
+			}
+		}
+	})
+
+	return m, nil
+}
+
+// addressRoutes returns the routes to the service address. It must be called with the table lock held.
+func (t *MemoryTable) addressRoutes(service, address string) []Route {
+	var routes []Route
+	for _, route := range t.routes[service] {
+		if route.Address == address {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// setWeight updates the weight of the routes to the service address emitting Update events.
+// It must be called with the table lock held.
+func (t *MemoryTable) setWeight(service, address string, weight int) {
+	for _, route := range t.addressRoutes(service, address) {
+		if route.Weight == weight {
+			continue
+		}
+		route.Weight = weight
+		t.adjust(route, CauseMigration)
+	}
+}
+
+// shift sets the route weights of the migration step. It must be called with the table lock held.
+func (m *Migration) shift(step int) {
+	weight := 100 * step / m.steps
+	m.t.setWeight(m.service, m.from, 100-weight)
+	m.t.setWeight(m.service, m.to, weight)
+}
+
+// next advances the migration by one step, deleting the old routes after the last one.
+// It returns true once the migration has completed or it has been aborted.
+func (m *Migration) next() bool {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.stopped {
+		return true
+	}
+
+	m.t.Lock()
+	defer m.t.Unlock()
+
+	if m.t.leased(m.service, m.from) || m.t.leased(m.service, m.to) {
+		m.stop()
+		return true
+	}
+
+	if m.step < m.steps {
+		m.step++
+		m.shift(m.step)
+		return false
+	}
+
+	for _, route := range m.t.addressRoutes(m.service, m.from) {
+		m.t.remove(route, CauseMigration)
+	}
+
+	m.stop()
+
+	return true
+}
+
+// stop marks the migration stopped. It must be called with the migration lock held.
+func (m *Migration) stop() {
+	if !m.stopped {
+		m.stopped = true
+		close(m.done)
+	}
+}
+
+// Abort stops the migration leaving the route weights as they are
+func (m *Migration) Abort() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.stop()
+}
+
+// Step returns the number of the migration steps made
+func (m *Migration) Step() int {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.step
+}
+
+// Done returns the channel closed once the migration has completed, it has been aborted
+// or the table has been closed
+func (m *Migration) Done() <-chan struct{} {
+	return m.done
+}
@@ -0,0 +1,171 @@
+package router
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMigrate(t *testing.T) {
+	table, route := testSetup()
+	defer table.Close()
+
+	for _, address := range []string{"old.addr", "new.addr"} {
+		route.Address = address
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	if _, err := table.Migrate(route.Service, "old.addr", "missing.addr", 4, time.Hour); err != ErrRouteNotFound {
+		t.Errorf("expected error: %s, found: %v", ErrRouteNotFound, err)
+	}
+
+	// the steps are made manually instead of waiting for the interval
+	m, err := table.Migrate(route.Service, "old.addr", "new.addr", 4, time.Hour)
+	if err != nil {
+		t.Fatalf("error starting migration: %s", err)
+	}
+
+	weights := func() map[string]int {
+		routes, _ := table.List()
+		result := make(map[string]int)
+		for _, r := range routes {
+			result[r.Address] = r.Weight
+		}
+		return result
+	}
+
+	if w := weights(); w["old.addr"] != 100 || w["new.addr"] != 0 {
+		t.Fatalf("expected initial weights 100 and 0, found: %v", w)
+	}
+
+	for step, expected := range []int{25, 50, 75, 100} {
+		if m.next() {
+			t.Fatalf("migration completed early at step %d", step+1)
+		}
+		if w := weights(); w["old.addr"] != 100-expected || w["new.addr"] != expected {
+			t.Errorf("step %d: expected weights %d and %d, found: %v", step+1, 100-expected, expected, w)
+		}
+	}
+
+	// the old route is deleted once the new one carries all the traffic
+	if !m.next() {
+		t.Fatalf("expected migration to complete")
+	}
+
+	if w := weights(); len(w) != 1 || w["new.addr"] != 100 {
+		t.Errorf("expected only the new route, found: %v", w)
+	}
+
+	select {
+	case <-m.Done():
+	default:
+		t.Errorf("expected migration to be done")
+	}
+}
+
+func TestMigrateAbort(t *testing.T) {
+	table, route := testSetup()
+	defer table.Close()
+
+	for _, address := range []string{"old.addr", "new.addr"} {
+		route.Address = address
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	m, err := table.Migrate(route.Service, "old.addr", "new.addr", 2, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("error starting migration: %s", err)
+	}
+
+	// wait for the first step
+	deadline := time.Now().Add(time.Second)
+	for m.Step() < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	m.Abort()
+	step := m.Step()
+
+	time.Sleep(50 * time.Millisecond)
+
+	if m.Step() != step {
+		t.Errorf("expected the aborted migration to stop at step %d, found: %d", step, m.Step())
+	}
+
+	if routes, _ := table.List(); len(routes) != 2 && step < 2 {
+		t.Errorf("expected the old route to be kept, found %d routes", len(routes))
+	}
+
+	select {
+	case <-m.Done():
+	default:
+		t.Errorf("expected aborted migration to be done")
+	}
+}
+
+func TestMigrateLeased(t *testing.T) {
+	table, route := testSetup()
+	defer table.Close()
+
+	for _, address := range []string{"old.addr", "new.addr"} {
+		route.Address = address
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	m, err := table.Migrate(route.Service, "old.addr", "new.addr", 2, time.Hour)
+	if err != nil {
+		t.Fatalf("error starting migration: %s", err)
+	}
+
+	// leasing either address aborts the migration before its next step
+	if err := table.AcquireLease(route.Service, "new.addr", "router-a", time.Minute); err != nil {
+		t.Fatalf("error acquiring lease: %s", err)
+	}
+
+	if !m.next() || m.Step() != 0 {
+		t.Errorf("expected the migration to be aborted at step 0, found: %d", m.Step())
+	}
+
+	select {
+	case <-m.Done():
+	default:
+		t.Errorf("expected aborted migration to be done")
+	}
+}
+
+func TestMigrateClose(t *testing.T) {
+	table, route := testSetup()
+
+	for _, address := range []string{"old.addr", "new.addr"} {
+		route.Address = address
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	if _, err := table.Migrate(route.Service, "old.addr", "new.addr", 2, 0); err != ErrInvalidInterval {
+		t.Errorf("expected error: %s, found: %v", ErrInvalidInterval, err)
+	}
+
+	m, err := table.Migrate(route.Service, "old.addr", "new.addr", 2, time.Hour)
+	if err != nil {
+		t.Fatalf("error starting migration: %s", err)
+	}
+
+	table.Close()
+
+	select {
+	case <-m.Done():
+	case <-time.After(time.Second):
+		t.Errorf("expected migration to be done once the table is closed")
+	}
+
+	if _, err := table.Migrate(route.Service, "old.addr", "new.addr", 2, time.Hour); err != ErrTableClosed {
+		t.Errorf("expected error: %s, found: %v", ErrTableClosed, err)
+	}
+}
@@ -0,0 +1,221 @@
+package router
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// holdCheckInterval is the maximum interval in which the held events are checked.
+// The multi watcher clock may not advance in real time, e.g. in tests.
+const holdCheckInterval = 100 * time.Millisecond
+
+// MultiWatchOption sets multi watcher options
+type MultiWatchOption func(*MultiWatchOptions)
+
+// MultiWatchOptions are multi watcher options
+type MultiWatchOptions struct {
+	// Window is the reordering buffer window
+	Window time.Duration
+	// Clock returns the current time
+	Clock func() time.Time
+}
+
+// OrderWindow orders the merged events by their timestamp, holding each event for the
+// window after it has arrived so that events of other watchers emitted before it can
+// overtake it. A larger window gives a more accurate global order at the cost of delivery
+// latency; events arriving later than the window after the events emitted after them are
+// delivered out of order. The window is measured with the multi watcher clock rather than
+// the event timestamps, so the skew between the clocks of the tables only affects the order.
+func OrderWindow(d time.Duration) MultiWatchOption {
+	return func(o *MultiWatchOptions) {
+		o.Window = d
+	}
+}
+
+// MultiWatchClock sets the clock measuring the order window, time.Now by default
+func MultiWatchClock(fn func() time.Time) MultiWatchOption {
+	return func(o *MultiWatchOptions) {
+		o.Clock = fn
+	}
+}
+
+// heldEvent is a merged event held in the reordering buffer
+type heldEvent struct {
+	event   *Event
+	arrived time.Time
+}
+
+// multiWatcher fans in the events of multiple watchers
+type multiWatcher struct {
+	opts     MultiWatchOptions
+	watchers []Watcher
+	events   chan *Event
+	resChan  chan *Event
+	done     chan struct{}
+	once     sync.Once
+	// sources is closed once all the merged watchers have stopped
+	sources chan struct{}
+}
+
+// NewMultiWatcher returns a watcher merging the events of the given watchers,
+// e.g. of watchers of multiple tables. Stopping it stops all the merged watchers.
+// Once all the merged watchers have stopped the remaining events are delivered and
+// then Next returns ErrWatcherStopped.
+func NewMultiWatcher(watchers []Watcher, opts ...MultiWatchOption) Watcher {
+	options := MultiWatchOptions{
+		Clock: time.Now,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	w := &multiWatcher{
+		opts:     options,
+		watchers: watchers,
+		events:   make(chan *Event),
+		resChan:  make(chan *Event, 10),
+		done:     make(chan struct{}),
+		sources:  make(chan struct{}),
+	}
+
+	var wg sync.WaitGroup
+	for _, watcher := range watchers {
+		wg.Add(1)
+		go func(watcher Watcher) {
+			defer wg.Done()
+			w.watch(watcher)
+		}(watcher)
+	}
+
+	go func() {
+		wg.Wait()
+		close(w.sources)
+	}()
+
+	go w.merge()
+
+	return w
+}
+
+// watch forwards the events of the watcher until it's stopped
+func (w *multiWatcher) watch(watcher Watcher) {
+	for {
+		event, err := watcher.Next()
+		if err != nil {
+			return
+		}
+
+		select {
+		case w.events <- event:
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// merge delivers the forwarded events, reordering them within the window.
+// It closes the result channel once all the merged watchers have stopped.
+func (w *multiWatcher) merge() {
+	// buffer holds the events sorted by timestamp
+	var buffer []heldEvent
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	sources := w.sources
+
+	for {
+		// release the events which have been held for the window, or all of them
+		// once there are no more events to reorder them with
+		for len(buffer) > 0 && (sources == nil || w.opts.Clock().Sub(buffer[0].arrived) >= w.opts.Window) {
+			select {
+			case w.resChan <- buffer[0].event:
+				buffer = buffer[1:]
+			case <-w.done:
+				return
+			}
+		}
+
+		if sources == nil {
+			close(w.resChan)
+			return
+		}
+
+		var wait <-chan time.Time
+		if len(buffer) > 0 {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			d := w.opts.Window - w.opts.Clock().Sub(buffer[0].arrived)
+			if d > holdCheckInterval {
+				d = holdCheckInterval
+			}
+			timer.Reset(d)
+			wait = timer.C
+		}
+
+		select {
+		case event := <-w.events:
+			i := sort.Search(len(buffer), func(i int) bool {
+				return buffer[i].event.Timestamp.After(event.Timestamp)
+			})
+			buffer = append(buffer, heldEvent{})
+			copy(buffer[i+1:], buffer[i:])
+			buffer[i] = heldEvent{event: event, arrived: w.opts.Clock()}
+		case <-wait:
+		case <-sources:
+			// the stopped sources have forwarded all their events
+			sources = nil
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Next is a blocking call that returns the next merged event
+func (w *multiWatcher) Next() (*Event, error) {
+	select {
+	case event, ok := <-w.resChan:
+		if !ok {
+			return nil, ErrWatcherStopped
+		}
+		return event, nil
+	case <-w.done:
+		return nil, ErrWatcherStopped
+	}
+}
+
+// Chan returns the merged event channel
+func (w *multiWatcher) Chan() (<-chan *Event, error) {
+	return w.resChan, nil
+}
+
+// Len returns the number of buffered events
+func (w *multiWatcher) Len() int {
+	return len(w.resChan)
+}
+
+// UpdateFilter changes what routes all the merged watchers watch
+func (w *multiWatcher) UpdateFilter(opts ...WatchOption) error {
+	for _, watcher := range w.watchers {
+		if err := watcher.UpdateFilter(opts...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stop stops the multi watcher and all the merged watchers
+func (w *multiWatcher) Stop() {
+	w.once.Do(func() {
+		close(w.done)
+		for _, watcher := range w.watchers {
+			watcher.Stop()
+		}
+	})
+}
@@ -0,0 +1,180 @@
+package router
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// testWatcher is a watcher delivering events pushed by the test
+type testWatcher struct {
+	events chan *Event
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newTestWatcher() *testWatcher {
+	return &testWatcher{
+		events: make(chan *Event, 10),
+		done:   make(chan struct{}),
+	}
+}
+
+func (w *testWatcher) Next() (*Event, error) {
+	select {
+	case e := <-w.events:
+		return e, nil
+	case <-w.done:
+		return nil, ErrWatcherStopped
+	}
+}
+
+func (w *testWatcher) Chan() (<-chan *Event, error) {
+	return w.events, nil
+}
+
+func (w *testWatcher) Len() int {
+	return len(w.events)
+}
+
+func (w *testWatcher) UpdateFilter(...WatchOption) error {
+	return nil
+}
+
+func (w *testWatcher) Stop() {
+	w.once.Do(func() {
+		close(w.done)
+	})
+}
+
+func TestMultiWatcher(t *testing.T) {
+	w1, w2 := newTestWatcher(), newTestWatcher()
+
+	w := NewMultiWatcher([]Watcher{w1, w2})
+	defer w.Stop()
+
+	w1.events <- &Event{Seq: 1, Timestamp: time.Now()}
+	w2.events <- &Event{Seq: 1, Timestamp: time.Now()}
+
+	for i := 0; i < 2; i++ {
+		if _, err := w.Next(); err != nil {
+			t.Fatalf("error receiving event: %s", err)
+		}
+	}
+
+	w.Stop()
+
+	if _, err := w.Next(); err != ErrWatcherStopped {
+		t.Errorf("expected error: %s, found: %v", ErrWatcherStopped, err)
+	}
+
+	// the merged watchers are stopped
+	if _, err := w1.Next(); err != ErrWatcherStopped {
+		t.Errorf("expected error: %s, found: %v", ErrWatcherStopped, err)
+	}
+}
+
+func TestMultiWatcherOrder(t *testing.T) {
+	w1, w2 := newTestWatcher(), newTestWatcher()
+
+	w := NewMultiWatcher([]Watcher{w1, w2}, OrderWindow(100*time.Millisecond))
+	defer w.Stop()
+
+	now := time.Now()
+
+	// interleaved events of two tables arriving out of order
+	w1.events <- &Event{Id: "3", Timestamp: now.Add(3 * time.Millisecond)}
+	w2.events <- &Event{Id: "2", Timestamp: now.Add(2 * time.Millisecond)}
+	w1.events <- &Event{Id: "4", Timestamp: now.Add(4 * time.Millisecond)}
+	w2.events <- &Event{Id: "1", Timestamp: now.Add(1 * time.Millisecond)}
+
+	for _, id := range []string{"1", "2", "3", "4"} {
+		event, err := w.Next()
+		if err != nil {
+			t.Fatalf("error receiving event: %s", err)
+		}
+		if event.Id != id {
+			t.Errorf("events out of order. Expected: %s, found: %s", id, event.Id)
+		}
+	}
+}
+
+func TestMultiWatcherClock(t *testing.T) {
+	w1, w2 := newTestWatcher(), newTestWatcher()
+
+	var mtx sync.Mutex
+	now := time.Now()
+	clock := func() time.Time {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return now
+	}
+
+	w := NewMultiWatcher([]Watcher{w1, w2}, OrderWindow(time.Minute), MultiWatchClock(clock))
+	defer w.Stop()
+
+	// the event timestamps are skewed far into the future
+	w1.events <- &Event{Id: "2", Timestamp: now.Add(2 * time.Hour)}
+	w2.events <- &Event{Id: "1", Timestamp: now.Add(time.Hour)}
+
+	received := make(chan *Event, 2)
+	go func() {
+		for i := 0; i < 2; i++ {
+			event, err := w.Next()
+			if err != nil {
+				return
+			}
+			received <- event
+		}
+	}()
+
+	// the events are held for the window measured with the clock
+	select {
+	case event := <-received:
+		t.Fatalf("unexpected event released before the window: %s", event.Id)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	mtx.Lock()
+	now = now.Add(2 * time.Minute)
+	mtx.Unlock()
+
+	for _, id := range []string{"1", "2"} {
+		select {
+		case event := <-received:
+			if event.Id != id {
+				t.Errorf("events out of order. Expected: %s, found: %s", id, event.Id)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("expected event %s to be released after the window", id)
+		}
+	}
+}
+
+func TestMultiWatcherSourcesStopped(t *testing.T) {
+	w1, w2 := newTestWatcher(), newTestWatcher()
+
+	w := NewMultiWatcher([]Watcher{w1, w2}, OrderWindow(time.Hour))
+	defer w.Stop()
+
+	w1.events <- &Event{Id: "1", Timestamp: time.Now()}
+
+	// wait for the event to be forwarded
+	deadline := time.Now().Add(time.Second)
+	for w1.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	w1.Stop()
+	w2.Stop()
+
+	// the held event is delivered once the sources have stopped
+	event, err := w.Next()
+	if err != nil || event.Id != "1" {
+		t.Fatalf("expected held event, found: %v, %v", event, err)
+	}
+
+	if _, err := w.Next(); err != ErrWatcherStopped {
+		t.Errorf("expected error: %s, found: %v", ErrWatcherStopped, err)
+	}
+}
@@ -1,8 +1,11 @@
 package router
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/micro/go-micro/v2/client"
+	"github.com/micro/go-micro/v2/logger"
 	"github.com/micro/go-micro/v2/registry"
 )
 
@@ -22,6 +25,8 @@ type Options struct {
 	Advertise Strategy
 	// Client for calling router
 	Client client.Client
+	// TableOptions are the routing table options
+	TableOptions []TableOption
 }
 
 // Id sets Router Id
@@ -73,6 +78,14 @@ func Advertise(a Strategy) Option {
 	}
 }
 
+// WithTableOptions sets the options of the routing table created by the router.
+// The options are applied when the router is created, Init does not apply them.
+func WithTableOptions(opts ...TableOption) Option {
+	return func(o *Options) {
+		o.TableOptions = append(o.TableOptions, opts...)
+	}
+}
+
 // DefaultOptions returns router default options
 func DefaultOptions() Options {
 	return Options{
@@ -83,3 +96,399 @@ func DefaultOptions() Options {
 		Advertise: AdvertiseLocal,
 	}
 }
+
+// TableOptions are routing table options
+type TableOptions struct {
+	// MetricNormalizer rescales route metrics on ingest
+	MetricNormalizer func(Route) int64
+	// UpdateComparator reports whether an updated route is unchanged
+	UpdateComparator func(old, new Route) bool
+	// ShadowFactor is the metric factor above which routes are shadowed
+	ShadowFactor float64
+	// ShadowWarnings logs a warning when a route is shadowed
+	ShadowWarnings bool
+	// DecayRate is the amount route metrics decay by on each interval
+	DecayRate int
+	// DecayInterval is the interval in which route metrics decay
+	DecayInterval time.Duration
+	// DecayDelta is the minimum metric decay applied to the routes at once
+	DecayDelta int64
+	// DispatchWorkers is the number of event dispatch workers
+	DispatchWorkers int
+	// Prober probes route reachability
+	Prober Prober
+	// ProbeCacheTTL is the time probe results are cached for
+	ProbeCacheTTL time.Duration
+	// Codec encodes routes for export and import
+	Codec Codec
+	// MaxWatchers is the maximum number of table watchers
+	MaxWatchers int
+	// MaxServiceWatchers is the maximum number of watchers of a single service
+	MaxServiceWatchers int
+	// CostFunc is the default route cost function
+	CostFunc CostFunc
+	// Clock returns the current time
+	Clock func() time.Time
+	// RouteTTL is the time after which routes which have not been updated expire
+	RouteTTL time.Duration
+	// MaxRoutes is the maximum number of routes in the table
+	MaxRoutes int
+	// Hasher returns the hash identifying the route
+	Hasher func(Route) uint64
+	// OnWatcherAdded is called when a watcher is created
+	OnWatcherAdded func(WatcherInfo)
+	// OnWatcherRemoved is called when a watcher is stopped
+	OnWatcherRemoved func(WatcherInfo)
+	// LatencyObserver is called with the duration of table operations
+	LatencyObserver func(op string, d time.Duration)
+	// MetadataMode defines how the metadata of updated routes is applied
+	MetadataMode MetadataMode
+	// StaleThreshold is the time after which routes which have not been updated are drained
+	StaleThreshold time.Duration
+	// DynamicRouteTTL is the time after which dynamic routes which have not been updated expire
+	DynamicRouteTTL time.Duration
+	// MinMetric is the minimum route metric
+	MinMetric int64
+	// MaxMetric is the maximum route metric
+	MaxMetric int64
+	// CascadeMode defines how the deletes cascade to the dependent routes
+	CascadeMode CascadeMode
+	// SinkMaxFailures is the number of consecutive failures after which event sinks are removed
+	SinkMaxFailures int
+	// EventRetention is the number of the last events retained by the table
+	EventRetention int
+	// StringInterning enables interning the route strings
+	StringInterning bool
+	// ActivationInterval is how often the scheduled routes are checked for activation
+	ActivationInterval time.Duration
+	// ImmutableFields are the route fields which can not be changed by updates
+	ImmutableFields []string
+	// CompactShadowed enables deleting the shadowed routes on table compaction
+	CompactShadowed bool
+	// CompactionObserver is called with the statistics of each table compaction
+	CompactionObserver func(CompactionStats)
+	// Logger is the table logger, the default logger if nil
+	Logger logger.Logger
+}
+
+// MetadataMode defines how route updates apply the route metadata
+type MetadataMode int
+
+const (
+	// MetadataReplace replaces the stored route metadata with the updated one
+	MetadataReplace MetadataMode = iota
+	// MetadataMerge merges the updated metadata into the stored one key by key.
+	// Keys with empty values are deleted from the stored metadata.
+	MetadataMerge
+)
+
+// CascadeMode defines how deleting a route applies to the routes depending on it
+type CascadeMode int
+
+const (
+	// CascadeDelete deletes the dependent routes
+	CascadeDelete CascadeMode = iota
+	// CascadeDown marks the dependent routes Down
+	CascadeDown
+)
+
+// TableOption used by the routing table
+type TableOption func(*TableOptions)
+
+// MetricNormalizer sets the function used to rescale the metric of routes
+// passed to Create and Update before they are stored in the table.
+// Normalization happens once at ingest; stored routes are never rescaled.
+func MetricNormalizer(fn func(Route) int64) TableOption {
+	return func(o *TableOptions) {
+		o.MetricNormalizer = fn
+	}
+}
+
+// UpdateComparator sets the function used to decide whether Update emits an event.
+// It returns true if the new route is considered unchanged from the stored one.
+// By default the routes are compared by all their fields but Id, Version and LastSeen,
+// which are managed by the table.
+func UpdateComparator(fn func(old, new Route) bool) TableOption {
+	return func(o *TableOptions) {
+		o.UpdateComparator = fn
+	}
+}
+
+// ShadowFactor sets the factor by which a route metric has to be worse than the
+// best metric for its destination for the route to be considered shadowed
+func ShadowFactor(f float64) TableOption {
+	return func(o *TableOptions) {
+		o.ShadowFactor = f
+	}
+}
+
+// ShadowWarnings enables logging a warning when Create or Update shadows a route
+func ShadowWarnings(b bool) TableOption {
+	return func(o *TableOptions) {
+		o.ShadowWarnings = b
+	}
+}
+
+// MetricDecay enables decaying elevated route metrics back toward the metric the route was
+// created with. On every interval the metric of each elevated route is reduced by rate,
+// emitting Update event caused by CauseHealth. Use MetricDecayDelta to throttle the events.
+// Decaying the route metric does not refresh the route.
+func MetricDecay(rate int, interval time.Duration) TableOption {
+	return func(o *TableOptions) {
+		o.DecayRate = rate
+		o.DecayInterval = interval
+	}
+}
+
+// DispatchWorkers sets the number of workers dispatching events to watchers.
+// Mutations hand their events over to a worker queue instead of dispatching them
+// themselves; events of the same route are always dispatched in order by the same worker.
+func DispatchWorkers(n int) TableOption {
+	return func(o *TableOptions) {
+		o.DispatchWorkers = n
+	}
+}
+
+// RouteProber sets the prober used to check route reachability
+func RouteProber(p Prober) TableOption {
+	return func(o *TableOptions) {
+		o.Prober = p
+	}
+}
+
+// ProbeCacheTTL sets the time probe results are cached for per gateway
+func ProbeCacheTTL(d time.Duration) TableOption {
+	return func(o *TableOptions) {
+		o.ProbeCacheTTL = d
+	}
+}
+
+// TableCodec sets the codec used to encode and decode routes
+func TableCodec(c Codec) TableOption {
+	return func(o *TableOptions) {
+		o.Codec = c
+	}
+}
+
+// MaxWatchers limits the number of table watchers. Creating more watchers returns ErrTooManyWatchers.
+func MaxWatchers(n int) TableOption {
+	return func(o *TableOptions) {
+		o.MaxWatchers = n
+	}
+}
+
+// MaxServiceWatchers limits the number of watchers of a single service.
+// Creating more watchers of the service returns ErrTooManyWatchers.
+func MaxServiceWatchers(n int) TableOption {
+	return func(o *TableOptions) {
+		o.MaxServiceWatchers = n
+	}
+}
+
+// TableCostFunc sets the default cost function used to select the best route
+func TableCostFunc(fn CostFunc) TableOption {
+	return func(o *TableOptions) {
+		o.CostFunc = fn
+	}
+}
+
+// TableClock sets the function returning the current time, e.g. to simulate time in tests
+func TableClock(fn func() time.Time) TableOption {
+	return func(o *TableOptions) {
+		o.Clock = fn
+	}
+}
+
+// RouteTTL enables expiring the routes which have not been created or updated within the ttl.
+// Expired routes are deleted by a background sweeper running twice per ttl or stale threshold.
+// The ttl applies to Learned routes, Static routes never expire and Dynamic routes expire
+// after the DynamicRouteTTL, by default a quarter of the ttl.
+func RouteTTL(ttl time.Duration) TableOption {
+	return func(o *TableOptions) {
+		o.RouteTTL = ttl
+	}
+}
+
+// StaleThreshold enables draining the healthy routes which have not been created or updated
+// within the threshold. Stale routes are marked Draining, emitting Update event, and they become
+// healthy again once they are updated. Combined with a longer RouteTTL the stale routes are
+// drained first and deleted once they expire.
+func StaleThreshold(d time.Duration) TableOption {
+	return func(o *TableOptions) {
+		o.StaleThreshold = d
+	}
+}
+
+// DynamicRouteTTL enables expiring the Dynamic routes which have not been created or updated
+// within the ttl. By default Dynamic routes expire after a quarter of the RouteTTL.
+func DynamicRouteTTL(ttl time.Duration) TableOption {
+	return func(o *TableOptions) {
+		o.DynamicRouteTTL = ttl
+	}
+}
+
+// MaxRoutes limits the number of routes in the table.
+// Adding a route to a full table evicts the least recently seen route, preferring
+// the Dynamic routes over the Learned ones. Static routes are never evicted.
+func MaxRoutes(n int) TableOption {
+	return func(o *TableOptions) {
+		o.MaxRoutes = n
+	}
+}
+
+// TableHasher sets the function hashing the route identity. The hash identifies the
+// routes in the table and it determines the table version, so all the tables which
+// are synced with each other or compare their versions must use the same hasher.
+// By default the fast non-cryptographic Route.Hash is used.
+func TableHasher(fn func(Route) uint64) TableOption {
+	return func(o *TableOptions) {
+		o.Hasher = fn
+	}
+}
+
+// OnWatcherAdded sets the hook called when a table watcher is created.
+// The hook is called outside of the table locks.
+func OnWatcherAdded(fn func(WatcherInfo)) TableOption {
+	return func(o *TableOptions) {
+		o.OnWatcherAdded = fn
+	}
+}
+
+// OnWatcherRemoved sets the hook called when a table watcher is stopped,
+// including the watchers stopped by closing the table.
+// The hook is called outside of the table locks.
+func OnWatcherRemoved(fn func(WatcherInfo)) TableOption {
+	return func(o *TableOptions) {
+		o.OnWatcherRemoved = fn
+	}
+}
+
+// TableLatencyObserver sets the function observing the duration of the table
+// lookup, create, update, upsert and delete operations, measured with the table clock.
+// The observer is called synchronously so it should be cheap, e.g. recording
+// the duration in a histogram.
+func TableLatencyObserver(fn func(op string, d time.Duration)) TableOption {
+	return func(o *TableOptions) {
+		o.LatencyObserver = fn
+	}
+}
+
+// MetadataMergeMode sets how route updates apply the route metadata.
+// By default the stored metadata is replaced.
+func MetadataMergeMode(m MetadataMode) TableOption {
+	return func(o *TableOptions) {
+		o.MetadataMode = m
+	}
+}
+
+// SinkMaxFailures sets the number of consecutive errors after which event sinks are removed.
+// By default failing sinks are never removed.
+func SinkMaxFailures(n int) TableOption {
+	return func(o *TableOptions) {
+		o.SinkMaxFailures = n
+	}
+}
+
+// EventRetention sets the number of the last emitted events retained by the table
+// for ChangedSince queries. Zero disables retaining the events. The older events are
+// released by RunCompactor, or once twice the number of the events has been retained.
+func EventRetention(n int) TableOption {
+	return func(o *TableOptions) {
+		o.EventRetention = n
+	}
+}
+
+// TableStringInterning enables sharing the memory of the equal route gateways, networks
+// and routers, reducing the memory of tables with many routes sharing a few gateways.
+func TableStringInterning(b bool) TableOption {
+	return func(o *TableOptions) {
+		o.StringInterning = b
+	}
+}
+
+// ImportOptions are route import options
+type ImportOptions struct {
+	// SkipInvalid continues the import past invalid routes
+	SkipInvalid bool
+}
+
+// ImportOption is used to configure the route import
+type ImportOption func(o *ImportOptions)
+
+// ImportSkipInvalid continues the import past invalid routes, reporting them instead of failing.
+func ImportSkipInvalid(b bool) ImportOption {
+	return func(o *ImportOptions) {
+		o.SkipInvalid = b
+	}
+}
+
+// TableMetricBounds clamps the metrics of routes passed to Create and Update into
+// the range, after they have been normalized, logging a warning for each clamped
+// metric. By default the metrics range from DefaultMinMetric to DefaultMaxMetric.
+func TableMetricBounds(min, max int64) TableOption {
+	return func(o *TableOptions) {
+		o.MinMetric = min
+		o.MaxMetric = max
+	}
+}
+
+// DependencyCascade sets how deleting a route applies to the routes depending on it,
+// see AddDependency. By default the dependent routes are deleted.
+func DependencyCascade(m CascadeMode) TableOption {
+	return func(o *TableOptions) {
+		o.CascadeMode = m
+	}
+}
+
+// ImmutableFields makes Update, Upsert and CompareAndUpdate return ErrImmutableField instead
+// of applying an update which changes any of the route fields. The fields are the Route field
+// names, matched case insensitively. Version and LastSeen are managed by the table so they
+// can't be immutable. Unknown fields are ignored, logging an error when the table is created.
+// The update changing the fields covered by the route hash is matched to the stored route by
+// the route id or by the route service and address, so it is rejected rather than creating
+// a new route.
+func ImmutableFields(fields ...string) TableOption {
+	return func(o *TableOptions) {
+		o.ImmutableFields = fields
+	}
+}
+
+// CompactShadowed makes the table compactions started by RunCompactor delete the shadowed
+// routes, see ShadowedRoutes. The static routes are kept. The deletes are caused by CauseCompaction.
+func CompactShadowed(b bool) TableOption {
+	return func(o *TableOptions) {
+		o.CompactShadowed = b
+	}
+}
+
+// TableCompactionObserver sets the function observing the statistics of the table compactions
+// started by RunCompactor, e.g. to record how much has been reclaimed.
+func TableCompactionObserver(fn func(CompactionStats)) TableOption {
+	return func(o *TableOptions) {
+		o.CompactionObserver = fn
+	}
+}
+
+// MetricDecayDelta throttles the Update events emitted by MetricDecay. The decay is accumulated
+// until the route metric can be reduced by at least delta, or down to its baseline, at once.
+func MetricDecayDelta(delta int64) TableOption {
+	return func(o *TableOptions) {
+		o.DecayDelta = delta
+	}
+}
+
+// ActivationInterval sets how often the scheduled routes are checked for activation,
+// DefaultActivationInterval by default or if the interval is not positive. The routes are
+// activated at most the interval late.
+func ActivationInterval(d time.Duration) TableOption {
+	return func(o *TableOptions) {
+		o.ActivationInterval = d
+	}
+}
+
+// TableLogger sets the logger of the table messages, logger.DefaultLogger by default.
+func TableLogger(l logger.Logger) TableOption {
+	return func(o *TableOptions) {
+		o.Logger = l
+	}
+}
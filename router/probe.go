@@ -0,0 +1,101 @@
+package router
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultProbeCacheTTL is the default time probe results are cached for
+	DefaultProbeCacheTTL = 10 * time.Second
+	// ErrNoProber is returned when probing routes without a prober
+	ErrNoProber = errors.New("no route prober")
+)
+
+// Prober probes the reachability of the given gateway address
+type Prober func(address string) error
+
+// probeResult is a cached probe result
+type probeResult struct {
+	err     error
+	expires time.Time
+}
+
+// probeCall is a probe in progress shared by the callers probing the same address
+type probeCall struct {
+	done chan struct{}
+	err  error
+}
+
+// probeCache caches probe results per gateway address
+type probeCache struct {
+	sync.Mutex
+	results map[string]probeResult
+	calls   map[string]*probeCall
+}
+
+func newProbeCache() *probeCache {
+	return &probeCache{
+		results: make(map[string]probeResult),
+		calls:   make(map[string]*probeCall),
+	}
+}
+
+// prune deletes the expired probe results. It must be called with the cache lock held.
+func (c *probeCache) prune(now time.Time) {
+	for address, res := range c.results {
+		if !now.Before(res.expires) {
+			delete(c.results, address)
+		}
+	}
+}
+
+// Probe checks the reachability of the route using the configured prober.
+// Results are cached per gateway for the probe cache TTL so that routes sharing
+// a gateway reuse a single probe. Routes without a gateway probe their address.
+// Concurrent probes of the same address wait for a single probe while the probes
+// of the other addresses are not blocked.
+func (t *MemoryTable) Probe(r Route) error {
+	if t.opts.Prober == nil {
+		return ErrNoProber
+	}
+
+	address := r.Gateway
+	if len(address) == 0 {
+		address = r.Address
+	}
+
+	t.probes.Lock()
+	if res, ok := t.probes.results[address]; ok && t.opts.Clock().Before(res.expires) {
+		t.probes.Unlock()
+		return res.err
+	}
+
+	if call, ok := t.probes.calls[address]; ok {
+		t.probes.Unlock()
+		<-call.done
+		return call.err
+	}
+
+	call := &probeCall{done: make(chan struct{})}
+	t.probes.calls[address] = call
+	t.probes.Unlock()
+
+	call.err = t.opts.Prober(address)
+
+	now := t.opts.Clock()
+
+	t.probes.Lock()
+	delete(t.probes.calls, address)
+	t.probes.prune(now)
+	t.probes.results[address] = probeResult{
+		err:     call.err,
+		expires: now.Add(t.opts.ProbeCacheTTL),
+	}
+	t.probes.Unlock()
+
+	close(call.done)
+
+	return call.err
+}
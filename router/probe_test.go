@@ -0,0 +1,133 @@
+package router
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProbeCache(t *testing.T) {
+	var probes int
+
+	prober := func(address string) error {
+		probes++
+		if address == "bad.gw" {
+			return errors.New("unreachable")
+		}
+		return nil
+	}
+
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	table := newTable(RouteProber(prober), ProbeCacheTTL(time.Minute), TableClock(clock))
+	_, route := testSetup()
+
+	// two routes sharing a gateway
+	route1 := route
+	route1.Address = "dest.addr1"
+	route2 := route
+	route2.Address = "dest.addr2"
+
+	for _, r := range []Route{route1, route2} {
+		if err := table.Probe(r); err != nil {
+			t.Fatalf("unexpected probe error: %s", err)
+		}
+	}
+
+	if probes != 1 {
+		t.Errorf("incorrect number of probes. Expected: %d, found: %d", 1, probes)
+	}
+
+	// failures are cached too
+	route.Gateway = "bad.gw"
+	for i := 0; i < 2; i++ {
+		if err := table.Probe(route); err == nil {
+			t.Errorf("expected probe error")
+		}
+	}
+
+	if probes != 2 {
+		t.Errorf("incorrect number of probes. Expected: %d, found: %d", 2, probes)
+	}
+
+	// results expire after the TTL
+	now = now.Add(2 * time.Minute)
+
+	if err := table.Probe(route1); err != nil {
+		t.Fatalf("unexpected probe error: %s", err)
+	}
+
+	if probes != 3 {
+		t.Errorf("incorrect number of probes. Expected: %d, found: %d", 3, probes)
+	}
+
+	// the expired results are pruned
+	table.probes.Lock()
+	cached := len(table.probes.results)
+	table.probes.Unlock()
+
+	if cached != 1 {
+		t.Errorf("incorrect number of cached results. Expected: %d, found: %d", 1, cached)
+	}
+
+	if err := newTable().Probe(route1); err != ErrNoProber {
+		t.Errorf("expected error: %s, found: %v", ErrNoProber, err)
+	}
+}
+
+func TestProbeConcurrent(t *testing.T) {
+	var mtx sync.Mutex
+	probes := make(map[string]int)
+	release := make(chan struct{})
+
+	prober := func(address string) error {
+		mtx.Lock()
+		probes[address]++
+		mtx.Unlock()
+		if address == "slow.gw" {
+			<-release
+		}
+		return nil
+	}
+
+	table := newTable(RouteProber(prober))
+	_, route := testSetup()
+
+	slow := route
+	slow.Gateway = "slow.gw"
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := table.Probe(slow); err != nil {
+				t.Errorf("unexpected probe error: %s", err)
+			}
+		}()
+	}
+
+	// the slow probe does not block probing the other gateways
+	done := make(chan error, 1)
+	go func() {
+		done <- table.Probe(route)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected probe error: %s", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected probe not to be blocked by the slow probe")
+	}
+
+	close(release)
+	wg.Wait()
+
+	if probes["slow.gw"] != 1 {
+		t.Errorf("expected a single probe of the slow gateway, found: %d", probes["slow.gw"])
+	}
+}
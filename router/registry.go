@@ -0,0 +1,150 @@
+package router
+
+import (
+	"sync"
+	"time"
+
+	"github.com/micro/go-micro/v2/logger"
+	"github.com/micro/go-micro/v2/registry"
+)
+
+// routesFromService converts the nodes of the service into routes
+func routesFromService(service *registry.Service) []Route {
+	routes := make([]Route, 0, len(service.Nodes))
+
+	for _, node := range service.Nodes {
+		route := Route{
+			Service: service.Name,
+			Address: node.Address,
+			Network: DefaultNetwork,
+			Link:    DefaultLink,
+			Metric:  DefaultLocalMetric,
+		}
+
+		// gateway, network and router can be overridden by node metadata
+		if node.Metadata != nil {
+			if gw, ok := node.Metadata["gateway"]; ok {
+				route.Gateway = gw
+			}
+			if net, ok := node.Metadata["network"]; ok && len(net) > 0 {
+				route.Network = net
+			}
+			if rtr, ok := node.Metadata["router"]; ok {
+				route.Router = rtr
+			}
+		}
+
+		routes = append(routes, route)
+	}
+
+	return routes
+}
+
+// RoutesFromRegistry queries the registry for the given service and returns a route for each of its nodes.
+// The route gateway, network and router are read from the "gateway", "network" and "router" node metadata.
+func RoutesFromRegistry(reg registry.Registry, service string) ([]Route, error) {
+	services, err := reg.GetService(service)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []Route
+	for _, srv := range services {
+		routes = append(routes, routesFromService(srv)...)
+	}
+
+	return routes, nil
+}
+
+// syncRegistry applies the routes of all registry services to the table.
+// It deletes the routes it has previously synced which are no longer in the registry.
+func syncRegistry(t Table, reg registry.Registry, synced map[uint64]Route) error {
+	services, err := reg.ListServices()
+	if err != nil {
+		return err
+	}
+
+	current := make(map[uint64]Route)
+
+	for _, service := range services {
+		routes, err := RoutesFromRegistry(reg, service.Name)
+		if err != nil {
+			continue
+		}
+		for _, route := range routes {
+			current[route.Hash()] = route
+		}
+	}
+
+	for sum, route := range current {
+		if _, ok := synced[sum]; ok {
+			err := t.Update(route)
+			// the synced route may have been expired, evicted or deleted
+			if err == ErrRouteNotFound {
+				err = t.Create(route)
+			}
+			if err != nil && err != ErrDuplicateRoute {
+				return err
+			}
+		} else if err := t.Create(route); err != nil && err != ErrDuplicateRoute {
+			return err
+		}
+		synced[sum] = route
+	}
+
+	for sum, route := range synced {
+		if _, ok := current[sum]; ok {
+			continue
+		}
+		if err := t.Delete(route); err != nil && err != ErrRouteNotFound {
+			return err
+		}
+		delete(synced, sum)
+	}
+
+	return nil
+}
+
+// SyncFromRegistry keeps the routing table updated with the routes of all services found in the registry.
+// The table is synced immediately and then on every interval until the returned stop function is called.
+// It returns ErrInvalidInterval if the interval is not positive.
+func SyncFromRegistry(t Table, reg registry.Registry, interval time.Duration) (stop func(), err error) {
+	if interval <= 0 {
+		return nil, ErrInvalidInterval
+	}
+
+	exit := make(chan bool)
+	synced := make(map[uint64]Route)
+
+	syncRoutes := func() {
+		if err := syncRegistry(t, reg, synced); err != nil {
+			if logger.V(logger.ErrorLevel, logger.DefaultLogger) {
+				logger.Errorf("Error syncing routes from registry: %v", err)
+			}
+		}
+	}
+
+	syncRoutes()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-exit:
+				return
+			case <-ticker.C:
+				syncRoutes()
+			}
+		}
+	}()
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			close(exit)
+		})
+	}, nil
+}
@@ -0,0 +1,185 @@
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/registry"
+	"github.com/micro/go-micro/v2/registry/memory"
+)
+
+func TestRoutesFromRegistry(t *testing.T) {
+	reg := memory.NewRegistry()
+
+	service := &registry.Service{
+		Name:    "foo",
+		Version: "1.0.0",
+		Nodes: []*registry.Node{
+			{
+				Id:      "foo-1",
+				Address: "10.0.0.1:8080",
+			},
+			{
+				Id:      "foo-2",
+				Address: "10.0.0.2:8080",
+				Metadata: map[string]string{
+					"gateway": "10.0.0.254:9093",
+					"network": "foo.network",
+				},
+			},
+		},
+	}
+
+	if err := reg.Register(service); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+
+	routes, err := RoutesFromRegistry(reg, "foo")
+	if err != nil {
+		t.Fatalf("error converting registry service: %v", err)
+	}
+
+	if len(routes) != 2 {
+		t.Fatalf("incorrect number of routes. Expected: %d, found: %d", 2, len(routes))
+	}
+
+	for _, route := range routes {
+		if route.Service != "foo" {
+			t.Errorf("incorrect route service. Expected: %s, found: %s", "foo", route.Service)
+		}
+
+		switch route.Address {
+		case "10.0.0.1:8080":
+			if route.Gateway != "" || route.Network != DefaultNetwork {
+				t.Errorf("incorrect default route: %+v", route)
+			}
+		case "10.0.0.2:8080":
+			if route.Gateway != "10.0.0.254:9093" || route.Network != "foo.network" {
+				t.Errorf("route metadata not applied: %+v", route)
+			}
+		default:
+			t.Errorf("unexpected route address: %s", route.Address)
+		}
+	}
+
+	if _, err := RoutesFromRegistry(reg, "bar"); err != registry.ErrNotFound {
+		t.Errorf("expected error: %v, found: %v", registry.ErrNotFound, err)
+	}
+}
+
+func TestSyncFromRegistry(t *testing.T) {
+	reg := memory.NewRegistry()
+	table := newTable()
+
+	service := &registry.Service{
+		Name:    "foo",
+		Version: "1.0.0",
+		Nodes: []*registry.Node{
+			{Id: "foo-1", Address: "10.0.0.1:8080"},
+			{Id: "foo-2", Address: "10.0.0.2:8080"},
+		},
+	}
+
+	if err := reg.Register(service); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+
+	stop, err := SyncFromRegistry(table, reg, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("error syncing routes from registry: %v", err)
+	}
+	defer stop()
+
+	routes, err := table.Query(QueryService("foo"))
+	if err != nil {
+		t.Fatalf("error looking up routes: %v", err)
+	}
+
+	if len(routes) != 2 {
+		t.Fatalf("incorrect number of routes. Expected: %d, found: %d", 2, len(routes))
+	}
+
+	// deregister one of the nodes
+	if err := reg.Deregister(&registry.Service{
+		Name:    "foo",
+		Version: "1.0.0",
+		Nodes:   []*registry.Node{{Id: "foo-2", Address: "10.0.0.2:8080"}},
+	}); err != nil {
+		t.Fatalf("failed to deregister node: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(routes) != 1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		routes, _ = table.Query(QueryService("foo"))
+	}
+
+	if len(routes) != 1 {
+		t.Fatalf("incorrect number of routes. Expected: %d, found: %d", 1, len(routes))
+	}
+
+	if routes[0].Address != "10.0.0.1:8080" {
+		t.Errorf("incorrect route retained: %s", routes[0].Address)
+	}
+}
+
+func TestSyncFromRegistryInterval(t *testing.T) {
+	table := newTable()
+	defer table.Close()
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		if _, err := SyncFromRegistry(table, memory.NewRegistry(), interval); err != ErrInvalidInterval {
+			t.Errorf("expected error: %s, found: %v", ErrInvalidInterval, err)
+		}
+	}
+}
+
+func TestSyncRegistryRecreate(t *testing.T) {
+	reg := memory.NewRegistry()
+	table := newTable()
+
+	service := &registry.Service{
+		Name:    "foo",
+		Version: "1.0.0",
+		Nodes: []*registry.Node{
+			{Id: "foo-1", Address: "10.0.0.1:8080"},
+			{Id: "foo-2", Address: "10.0.0.2:8080"},
+		},
+	}
+
+	if err := reg.Register(service); err != nil {
+		t.Fatalf("failed to register service: %v", err)
+	}
+
+	synced := make(map[uint64]Route)
+	if err := syncRegistry(table, reg, synced); err != nil {
+		t.Fatalf("error syncing registry: %v", err)
+	}
+
+	// the synced route is deleted from the table, e.g. it has expired
+	routes, _ := table.Query(QueryService("foo"))
+	for _, route := range routes {
+		if route.Address == "10.0.0.1:8080" {
+			if err := table.Delete(route); err != nil {
+				t.Fatalf("error deleting route: %v", err)
+			}
+		}
+	}
+
+	if err := reg.Deregister(&registry.Service{
+		Name:    "foo",
+		Version: "1.0.0",
+		Nodes:   []*registry.Node{{Id: "foo-2", Address: "10.0.0.2:8080"}},
+	}); err != nil {
+		t.Fatalf("failed to deregister node: %v", err)
+	}
+
+	if err := syncRegistry(table, reg, synced); err != nil {
+		t.Fatalf("error syncing registry: %v", err)
+	}
+
+	routes, _ = table.Query(QueryService("foo"))
+	if len(routes) != 1 || routes[0].Address != "10.0.0.1:8080" {
+		t.Errorf("expected deleted route to be recreated and deregistered one deleted, found: %v", routes)
+	}
+}
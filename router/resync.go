@@ -0,0 +1,87 @@
+package router
+
+import (
+	"time"
+)
+
+// resync corrects the drift of the watcher view from the table every interval
+func (t *MemoryTable) resync(w *tableWatcher, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			t.correct(w)
+		case <-w.done:
+			return
+		case <-t.exit:
+			return
+		}
+	}
+}
+
+// correct delivers the events converging the watcher view to the watched table routes
+func (t *MemoryTable) correct(w *tableWatcher) {
+	// synchronous watchers wait for the acknowledgements outside of the table lock
+	for _, e := range t.corrections(w) {
+		t.deliverSync(w, e)
+	}
+}
+
+// corrections computes the events converging the watcher view to the watched table routes
+// and delivers them unless the watcher is synchronous, in which case they are returned.
+// The table lock is held so that the events already dispatched are older than the corrections.
+func (t *MemoryTable) corrections(w *tableWatcher) []*Event {
+	t.RLock()
+	defer t.RUnlock()
+
+	var routes []Route
+	lastSeen := make(map[uint64]time.Time)
+	for _, route := range t.list() {
+		if w.match(&Event{Route: route}) {
+			routes = append(routes, route)
+			lastSeen[w.hash(route)] = route.LastSeen
+		}
+	}
+
+	w.RLock()
+	view := make([]Route, 0, len(w.view))
+	for sum, route := range w.view {
+		// refreshing the routes emits no events
+		if seen, ok := lastSeen[sum]; ok {
+			route.LastSeen = seen
+		}
+		view = append(view, route)
+	}
+	w.RUnlock()
+
+	var pending []*Event
+
+	for _, e := range diff(view, routes, w.hash) {
+		e.Seq = t.seq
+		e.Timestamp = t.opts.Clock()
+		e.TableVersion = t.version
+		e.Cause = CauseResync
+
+		switch {
+		case w.opts.LevelTriggered:
+			w.collapse(e)
+		case w.acks != nil:
+			pending = append(pending, e)
+		default:
+			// the dropped corrections are delivered by the next resync
+			select {
+			case w.resChan <- e:
+				t.stats.incDelivered()
+				w.record(e)
+			case <-w.done:
+				return nil
+			default:
+				t.stats.incDropped()
+			}
+		}
+	}
+
+	return pending
+}
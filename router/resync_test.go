@@ -0,0 +1,72 @@
+package router
+
+import (
+	"testing"
+	"time"
+)
+
+// silently mutates the table without emitting any event
+func dropEvents(table *MemoryTable, fn func()) {
+	table.Lock()
+	table.warming = true
+	fn()
+	table.warming = false
+	table.Unlock()
+}
+
+func TestWatchWithResync(t *testing.T) {
+	table := newTable()
+	defer table.Close()
+
+	_, route := testSetup()
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	w, err := table.watch(WatchWithResync(50 * time.Millisecond))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	// the view starts from the existing routes and refreshing them emits no corrections
+	if err := table.Refresh(route.Service, route.Address); err != nil {
+		t.Fatalf("error refreshing route: %s", err)
+	}
+	if events := collectEvents(w, 200*time.Millisecond); len(events) != 0 {
+		t.Fatalf("expected no corrections, found: %d", len(events))
+	}
+
+	missed := route
+	missed.Address = "dest.addr-missed"
+
+	dropEvents(table, func() {
+		table.create(missed, CauseDirect)
+		table.remove(route, CauseDirect)
+	})
+
+	events := make(map[string]*Event)
+	for len(events) < 2 {
+		e, err := w.Next()
+		if err != nil {
+			t.Fatalf("error receiving event: %s", err)
+		}
+		if e.Cause != CauseResync {
+			t.Errorf("expected cause: %s, found: %s", CauseResync, e.Cause)
+		}
+		events[e.Route.Address] = e
+	}
+
+	if e, ok := events[missed.Address]; !ok || e.Type != Create {
+		t.Errorf("expected create correction of the missed route, found: %v", e)
+	}
+	if e, ok := events[route.Address]; !ok || e.Type != Delete {
+		t.Errorf("expected delete correction of the removed route, found: %v", e)
+	}
+
+	// the corrected view no longer drifts
+	if events := collectEvents(w, 200*time.Millisecond); len(events) != 0 {
+		t.Errorf("expected no corrections, found: %d", len(events))
+	}
+}
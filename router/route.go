@@ -2,17 +2,76 @@ package router
 
 import (
 	"hash/fnv"
+	"strconv"
+	"time"
 )
 
 var (
+	// GroupMetadataKey is the route metadata key storing the route group
+	GroupMetadataKey = "group"
 	// DefaultLink is default network link
 	DefaultLink = "local"
 	// DefaultLocalMetric is default route cost for a local route
 	DefaultLocalMetric int64 = 1
 )
 
+// RouteStatus is route status
+type RouteStatus int
+
+const (
+	// Healthy means the route can be used
+	Healthy RouteStatus = iota
+	// Draining means the route is being taken out of service
+	Draining
+	// Down means the route can not be used
+	Down
+)
+
+// String returns human readable route status
+func (s RouteStatus) String() string {
+	switch s {
+	case Healthy:
+		return "healthy"
+	case Draining:
+		return "draining"
+	case Down:
+		return "down"
+	default:
+		return "unknown"
+	}
+}
+
+// RouteClass defines the route lifecycle
+type RouteClass int
+
+const (
+	// Learned routes expire after the table route ttl
+	Learned RouteClass = iota
+	// Static routes never expire nor are evicted
+	Static
+	// Dynamic routes expire after the short dynamic route ttl and are evicted first
+	Dynamic
+)
+
+// String returns human readable route class
+func (c RouteClass) String() string {
+	switch c {
+	case Learned:
+		return "learned"
+	case Static:
+		return "static"
+	case Dynamic:
+		return "dynamic"
+	default:
+		return "unknown"
+	}
+}
+
 // Route is network route
 type Route struct {
+	// Id is the route id assigned by the table when the route is created.
+	// It is derived from the route identity so it is stable across updates.
+	Id string
 	// Service is destination service name
 	Service string
 	// Address is service node address
@@ -27,6 +86,21 @@ type Route struct {
 	Link string
 	// Metric is the route cost metric
 	Metric int64
+	// Version is bumped by the table on each mutation of the route
+	Version uint64
+	// Status is the route status
+	Status RouteStatus
+	// Class is the route class
+	Class RouteClass
+	// Weight is the share of the service traffic in percent routed via the route, zero if unweighted
+	Weight int
+	// Metadata is the route metadata
+	Metadata map[string]string
+	// LastSeen is the time the route has last been created or updated
+	LastSeen time.Time
+	// ActivateAt is the time the route becomes active. Until then the route
+	// is stored by the table but it is not returned by lookups nor advertised.
+	ActivateAt time.Time
 }
 
 // Hash returns route hash sum.
@@ -36,3 +110,8 @@ func (r *Route) Hash() uint64 {
 	h.Write([]byte(r.Service + r.Address + r.Gateway + r.Network + r.Router + r.Link))
 	return h.Sum64()
 }
+
+// routeId returns the route id for the route hash
+func routeId(sum uint64) string {
+	return strconv.FormatUint(sum, 16)
+}
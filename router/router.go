@@ -22,7 +22,7 @@ type Router interface {
 	Init(...Option) error
 	// Options returns the router options
 	Options() Options
-	// The routing table
+	// The routing table. The table of the default router is a *MemoryTable.
 	Table() Table
 	// Advertise advertises routes
 	Advertise() (<-chan *Advert, error)
@@ -46,7 +46,7 @@ type Table interface {
 	Create(Route) error
 	// Delete existing route from the routing table
 	Delete(Route) error
-	// Update route in the routing table
+	// Update existing route in the routing table
 	Update(Route) error
 	// List all routes in the table
 	List() ([]Route, error)
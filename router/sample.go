@@ -0,0 +1,55 @@
+package router
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// sampler samples a fraction of the events
+type sampler struct {
+	sync.Mutex
+	fraction float64
+	byMetric bool
+	rand     *rand.Rand
+	// total and count are the sum and the number of the weights of the seen events
+	total float64
+	count float64
+}
+
+func newSampler(fraction float64, byMetric bool) *sampler {
+	return &sampler{
+		fraction: fraction,
+		byMetric: byMetric,
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// weight returns the sampling weight of the route, decreasing with the route metric
+func weight(r Route) float64 {
+	metric := float64(r.Metric)
+	if metric < 0 {
+		metric = 0
+	}
+	return 1 / (1 + math.Log1p(metric))
+}
+
+// sample checks if the event is sampled
+func (s *sampler) sample(e *Event) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	p := s.fraction
+
+	// scale the probability by the event weight relative to the mean weight
+	// so the overall fraction of the sampled events is kept
+	if s.byMetric {
+		w := weight(e.Route)
+		s.total += w
+		s.count++
+		p = math.Min(1, p*w*s.count/s.total)
+	}
+
+	return s.rand.Float64() < p
+}
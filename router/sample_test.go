@@ -0,0 +1,95 @@
+package router
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWatchSample(t *testing.T) {
+	table := newTable(DispatchWorkers(1))
+	defer table.Close()
+
+	w, err := table.watch(WatchSample(0.25, false))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	_, route := testSetup()
+
+	received := make(chan int)
+	go func() {
+		var n int
+		for {
+			select {
+			case <-w.resChan:
+				n++
+			case <-time.After(200 * time.Millisecond):
+				received <- n
+				return
+			}
+		}
+	}()
+
+	const events = 4000
+	for i := 0; i < events; i++ {
+		route.Address = fmt.Sprintf("dest.addr-%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	if fraction := float64(<-received) / events; math.Abs(fraction-0.25) > 0.04 {
+		t.Errorf("expected fraction of sampled events: 0.25, found: %.3f", fraction)
+	}
+}
+
+func TestSampleByMetric(t *testing.T) {
+	s := newSampler(0.2, true)
+
+	const events = 20000
+	sampled := make(map[int64]int)
+
+	for i := 0; i < events; i++ {
+		// half of the events are of preferred routes
+		metric := int64(1)
+		if i%2 == 1 {
+			metric = 1000
+		}
+		if s.sample(&Event{Route: Route{Metric: metric}}) {
+			sampled[metric]++
+		}
+	}
+
+	if fraction := float64(sampled[1]+sampled[1000]) / events; math.Abs(fraction-0.2) > 0.02 {
+		t.Errorf("expected fraction of sampled events: 0.2, found: %.3f", fraction)
+	}
+
+	if sampled[1] <= 2*sampled[1000] {
+		t.Errorf("expected low metric routes to be sampled more, found: %v", sampled)
+	}
+}
+
+func TestWatchSampleFraction(t *testing.T) {
+	table := newTable()
+	defer table.Close()
+
+	for _, fraction := range []float64{0, -0.5, 1.5, math.NaN()} {
+		if _, err := table.Watch(WatchSample(fraction, false)); err != ErrInvalidSampleFraction {
+			t.Errorf("expected error: %s for fraction %v, found: %v", ErrInvalidSampleFraction, fraction, err)
+		}
+	}
+
+	// the whole fraction delivers all the events without sampling them
+	w, err := table.watch(WatchSample(1, false))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	if w.sampler != nil {
+		t.Errorf("expected the watcher delivering all the events not to sample them")
+	}
+}
@@ -0,0 +1,52 @@
+package router
+
+import (
+	"time"
+)
+
+// scheduled checks if the route activation time is in the future
+func (t *MemoryTable) scheduled(r Route) bool {
+	return r.ActivateAt.After(t.opts.Clock())
+}
+
+// startScheduler starts the route activation scheduler unless it is already running.
+// It must be called with the table lock held.
+func (t *MemoryTable) startScheduler() {
+	if t.scheduling {
+		return
+	}
+	t.scheduling = true
+
+	t.run(func() {
+		t.schedule(t.opts.ActivationInterval)
+	})
+}
+
+// schedule periodically activates the scheduled routes until the table is closed
+func (t *MemoryTable) schedule(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.exit:
+			return
+		case <-ticker.C:
+			t.activate()
+		}
+	}
+}
+
+// activate adds the scheduled routes whose activation time has arrived to the table
+func (t *MemoryTable) activate() {
+	t.Lock()
+	defer t.Unlock()
+
+	for sum, route := range t.pending {
+		if t.scheduled(route) {
+			continue
+		}
+		delete(t.pending, sum)
+		t.create(route, CauseSchedule)
+	}
+}
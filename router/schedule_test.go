@@ -0,0 +1,174 @@
+package router
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduledRoute(t *testing.T) {
+	clock := newTestClock()
+
+	table := newTable(TableClock(clock.Now))
+	defer table.Close()
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error watching table: %s", err)
+	}
+	defer w.Stop()
+
+	_, route := testSetup()
+	route.ActivateAt = clock.Now().Add(time.Minute)
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	if err := table.Create(route); err != ErrDuplicateRoute {
+		t.Errorf("expected error: %s, found: %v", ErrDuplicateRoute, err)
+	}
+
+	// the route is not active yet
+	table.activate()
+
+	if _, err := table.Query(QueryService(route.Service)); err != ErrRouteNotFound {
+		t.Errorf("expected error: %s, found: %v", ErrRouteNotFound, err)
+	}
+
+	if events := collectEvents(w, 50*time.Millisecond); len(events) != 0 {
+		t.Fatalf("expected no events, found: %d", len(events))
+	}
+
+	clock.Add(time.Minute + time.Second)
+	table.activate()
+
+	routes, err := table.Query(QueryService(route.Service))
+	if err != nil {
+		t.Fatalf("error looking up routes: %s", err)
+	}
+
+	if len(routes) != 1 {
+		t.Fatalf("expected 1 active route, found: %d", len(routes))
+	}
+
+	events := collectEvents(w, 100*time.Millisecond)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, found: %d", len(events))
+	}
+
+	if events[0].Type != Create || events[0].Cause != CauseSchedule {
+		t.Errorf("expected create caused by %s, found: %s", CauseSchedule, events[0])
+	}
+}
+
+func TestDeleteScheduledRoute(t *testing.T) {
+	clock := newTestClock()
+
+	table := newTable(TableClock(clock.Now))
+	defer table.Close()
+
+	_, route := testSetup()
+	route.ActivateAt = clock.Now().Add(time.Minute)
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	if err := table.Delete(route); err != nil {
+		t.Fatalf("error deleting route: %s", err)
+	}
+
+	clock.Add(2 * time.Minute)
+	table.activate()
+
+	if routes, _ := table.List(); len(routes) != 0 {
+		t.Errorf("expected deleted scheduled route not to be activated, found %d routes", len(routes))
+	}
+}
+
+func TestActivationInterval(t *testing.T) {
+	clock := newTestClock()
+
+	table := newTable(TableClock(clock.Now), ActivationInterval(10*time.Millisecond))
+	defer table.Close()
+
+	_, route := testSetup()
+	route.ActivateAt = clock.Now().Add(time.Minute)
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	// the scheduler activates the route once its activation time has arrived
+	clock.Add(2 * time.Minute)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if routes, _ := table.List(); len(routes) == 1 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Errorf("expected scheduled route to be activated by the scheduler")
+}
+
+func TestInvalidActivationInterval(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second} {
+		table := newTable(ActivationInterval(d))
+
+		if table.opts.ActivationInterval != DefaultActivationInterval {
+			t.Errorf("expected default activation interval, found: %s", table.opts.ActivationInterval)
+		}
+
+		// starting the scheduler must not panic
+		_, route := testSetup()
+		route.ActivateAt = time.Now().Add(time.Minute)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+
+		table.Close()
+	}
+}
+
+func TestSetScheduledRoutes(t *testing.T) {
+	clock := newTestClock()
+
+	table := newTable(TableClock(clock.Now))
+	defer table.Close()
+
+	_, route := testSetup()
+	route.ActivateAt = clock.Now().Add(time.Minute)
+
+	omitted := route
+	omitted.Address = "omitted.addr"
+
+	rescheduled := route
+	rescheduled.Address = "rescheduled.addr"
+
+	for _, r := range []Route{omitted, rescheduled} {
+		if err := table.Create(r); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	// the omitted scheduled route is deleted and the other one is rescheduled
+	rescheduled.ActivateAt = clock.Now().Add(time.Hour)
+	table.Set([]Route{rescheduled})
+
+	clock.Add(2 * time.Minute)
+	table.activate()
+
+	if routes, _ := table.List(); len(routes) != 0 {
+		t.Fatalf("expected no active routes, found: %v", routes)
+	}
+
+	clock.Add(time.Hour)
+	table.activate()
+
+	routes, _ := table.List()
+	if len(routes) != 1 || routes[0].Address != rescheduled.Address {
+		t.Errorf("expected only the rescheduled route to be activated, found: %v", routes)
+	}
+}
@@ -0,0 +1,103 @@
+package router
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// scoreSubstring is the score of a field containing the search term
+	scoreSubstring = 1
+	// scorePrefix is the score of a field starting with the search term
+	scorePrefix = 2
+	// scoreExact is the score of a field equal to the search term
+	scoreExact = 3
+)
+
+// ScoredRoute is a route matching the search query
+type ScoredRoute struct {
+	// Route is the matching route
+	Route Route
+	// Score is the route search score
+	Score int
+}
+
+// matchScore returns the score of the field matching the lower case term
+func matchScore(field, term string) int {
+	field = strings.ToLower(field)
+
+	switch {
+	case field == term:
+		return scoreExact
+	case strings.HasPrefix(field, term):
+		return scorePrefix
+	case strings.Contains(field, term):
+		return scoreSubstring
+	default:
+		return 0
+	}
+}
+
+// searchScore returns the search score of the route for the lower case terms
+func searchScore(r Route, terms []string) int {
+	var score int
+
+	for _, term := range terms {
+		fields := []string{r.Service, r.Address, r.Gateway}
+		for k, v := range r.Metadata {
+			fields = append(fields, k, v)
+		}
+
+		var best int
+		for _, field := range fields {
+			if s := matchScore(field, term); s > best {
+				best = s
+			}
+		}
+		score += best
+	}
+
+	return score
+}
+
+// Search returns up to limit routes matching the free text query, sorted by descending score.
+// The query is split into whitespace separated terms which are matched case insensitively
+// against the route service, address, gateway and metadata keys and values. Each term scores
+// its best match among the route fields: 3 if the field equals the term, 2 if the field starts
+// with the term and 1 if the field contains it. The route score is the sum of the term scores
+// and the routes which match no term are not returned. Routes with equal scores are sorted by
+// service and address. A limit of zero or less returns all the matching routes.
+func (t *MemoryTable) Search(query string, limit int) []ScoredRoute {
+	terms := strings.Fields(strings.ToLower(query))
+	if len(terms) == 0 {
+		return nil
+	}
+
+	t.RLock()
+	routes := t.list()
+	t.RUnlock()
+
+	var results []ScoredRoute
+	for _, route := range routes {
+		if score := searchScore(route, terms); score > 0 {
+			results = append(results, ScoredRoute{Route: route, Score: score})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Route.Service != b.Route.Service {
+			return a.Route.Service < b.Route.Service
+		}
+		return a.Route.Address < b.Route.Address
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
@@ -0,0 +1,60 @@
+package router
+
+import (
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	table, route := testSetup()
+	defer table.Close()
+
+	routes := []Route{
+		{Service: "payments.api", Address: "10.0.0.1"},
+		{Service: "payments", Address: "10.0.0.2"},
+		{Service: "legacy-payments", Address: "10.0.0.3"},
+		{Service: "billing", Address: "10.0.0.4", Metadata: map[string]string{"team": "Payments"}},
+		{Service: "orders", Address: "10.0.0.5"},
+	}
+
+	for _, r := range routes {
+		r.Gateway = route.Gateway
+		r.Network = route.Network
+		if err := table.Create(r); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	results := table.Search("Payments", 0)
+
+	// exact matches rank before prefix matches which rank before substring matches
+	expected := []struct {
+		address string
+		score   int
+	}{
+		{"10.0.0.4", 3},
+		{"10.0.0.2", 3},
+		{"10.0.0.1", 2},
+		{"10.0.0.3", 1},
+	}
+
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, found: %d", len(expected), len(results))
+	}
+
+	for i, e := range expected {
+		if results[i].Route.Address != e.address || results[i].Score != e.score {
+			t.Errorf("expected result %d: %s with score %d, found: %s with score %d",
+				i, e.address, e.score, results[i].Route.Address, results[i].Score)
+		}
+	}
+
+	// the scores of the terms add up
+	results = table.Search("payments 10.0.0.1", 1)
+	if len(results) != 1 || results[0].Route.Address != "10.0.0.1" || results[0].Score != 5 {
+		t.Errorf("expected top result 10.0.0.1 with score 5, found: %+v", results)
+	}
+
+	if results := table.Search(" ", 0); len(results) != 0 {
+		t.Errorf("expected no results for empty query, found: %d", len(results))
+	}
+}
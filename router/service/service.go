@@ -258,6 +258,8 @@ func (s *svc) Watch(opts ...router.WatchOption) (router.Watcher, error) {
 	}
 	options := router.WatchOptions{
 		Service: "*",
+		Router:  "*",
+		Network: "*",
 	}
 	for _, o := range opts {
 		o(&options)
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"errors"
 	"io"
 	"sync"
 	"time"
@@ -9,6 +10,11 @@ import (
 	pb "github.com/micro/go-micro/v2/router/service/proto"
 )
 
+var (
+	// ErrUnsupportedFilter is returned when updating the watcher filters other than the service
+	ErrUnsupportedFilter = errors.New("unsupported watch filter")
+)
+
 type watcher struct {
 	sync.RWMutex
 	opts    router.WatchOptions
@@ -85,7 +91,11 @@ func (w *watcher) Next() (*router.Event, error) {
 	for {
 		select {
 		case res := <-w.resChan:
-			switch w.opts.Service {
+			w.RLock()
+			service := w.opts.Service
+			w.RUnlock()
+
+			switch service {
 			case res.Route.Service, "*":
 				return res, nil
 			default:
@@ -102,6 +112,34 @@ func (w *watcher) Chan() (<-chan *router.Event, error) {
 	return w.resChan, nil
 }
 
+// Len returns the number of buffered events
+func (w *watcher) Len() int {
+	return len(w.resChan)
+}
+
+// UpdateFilter changes the service the watcher watches. The events are only filtered
+// by the service so changing the router, network, filter expression or address CIDR
+// returns ErrUnsupportedFilter, leaving the filter unchanged.
+func (w *watcher) UpdateFilter(opts ...router.WatchOption) error {
+	w.Lock()
+	defer w.Unlock()
+
+	var wopts router.WatchOptions
+	for _, o := range opts {
+		o(&wopts)
+	}
+
+	if len(wopts.Router) > 0 || len(wopts.Network) > 0 || len(wopts.Expr) > 0 || len(wopts.AddressCIDR) > 0 {
+		return ErrUnsupportedFilter
+	}
+
+	if len(wopts.Service) > 0 {
+		w.opts.Service = wopts.Service
+	}
+
+	return nil
+}
+
 // Stop stops watcher
 func (w *watcher) Stop() {
 	w.Lock()
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/micro/go-micro/v2/router"
+)
+
+func TestUpdateFilter(t *testing.T) {
+	w := &watcher{opts: router.WatchOptions{Service: "*"}}
+
+	if err := w.UpdateFilter(router.WatchService("foo")); err != nil {
+		t.Fatalf("error updating filter: %s", err)
+	}
+	if w.opts.Service != "foo" {
+		t.Errorf("expected service filter: %s, found: %s", "foo", w.opts.Service)
+	}
+
+	// the remote events are only filtered by the service
+	unsupported := []router.WatchOption{
+		router.WatchRouter("router"),
+		router.WatchNetwork("network"),
+		router.WatchExpr("metric > 10"),
+		router.WatchAddressCIDR("10.0.0.0/8"),
+	}
+
+	for _, o := range unsupported {
+		if err := w.UpdateFilter(router.WatchService("bar"), o); err != ErrUnsupportedFilter {
+			t.Errorf("expected error: %s, found: %v", ErrUnsupportedFilter, err)
+		}
+	}
+
+	if w.opts.Service != "foo" {
+		t.Errorf("expected unchanged service filter: %s, found: %s", "foo", w.opts.Service)
+	}
+}
@@ -0,0 +1,63 @@
+package router
+
+import (
+	"github.com/micro/go-micro/v2/logger"
+)
+
+// EventSink receives the routing table events pushed by the table
+type EventSink interface {
+	// Emit handles the event
+	Emit(*Event) error
+}
+
+// EventSinkFunc is an adapter allowing to use a function as an event sink
+type EventSinkFunc func(*Event) error
+
+// Emit calls the function with the event
+func (fn EventSinkFunc) Emit(e *Event) error {
+	return fn(e)
+}
+
+// ChannelSink returns an event sink sending the events to the channel
+func ChannelSink(ch chan<- *Event) EventSink {
+	return EventSinkFunc(func(e *Event) error {
+		ch <- e
+		return nil
+	})
+}
+
+// AddSink registers the sink receiving the table events which match the watch options.
+// The events are queued in a bounded watcher queue and pushed to the sink one at a time.
+// Sink errors are logged; the sink is removed once it has failed SinkMaxFailures times
+// in a row. The returned stop function removes the sink.
+func (t *MemoryTable) AddSink(sink EventSink, opts ...WatchOption) (stop func(), err error) {
+	w, err := t.Watch(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer w.Stop()
+
+		var failures int
+		for {
+			e, err := w.Next()
+			if err != nil {
+				return
+			}
+
+			if err := sink.Emit(e); err != nil {
+				failures++
+				t.logf(logger.ErrorLevel, "Error emitting event %s to sink: %v", e, err)
+				if t.opts.SinkMaxFailures > 0 && failures >= t.opts.SinkMaxFailures {
+					return
+				}
+				continue
+			}
+
+			failures = 0
+		}
+	}()
+
+	return w.Stop, nil
+}
@@ -0,0 +1,114 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSink captures the events it receives
+type testSink struct {
+	sync.Mutex
+	events []*Event
+	err    error
+}
+
+func (s *testSink) Emit(e *Event) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.events = append(s.events, e)
+	return s.err
+}
+
+func (s *testSink) len() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return len(s.events)
+}
+
+func waitForSink(s *testSink, n int) {
+	deadline := time.Now().Add(time.Second)
+	for s.len() < n && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAddSink(t *testing.T) {
+	table := newTable()
+	defer table.Close()
+
+	sink := &testSink{}
+
+	stop, err := table.AddSink(sink, WatchService("foo"))
+	if err != nil {
+		t.Fatalf("error adding sink: %s", err)
+	}
+
+	_, route := testSetup()
+
+	for i, service := range []string{"foo", "bar", "foo"} {
+		route.Service = service
+		route.Address = fmt.Sprintf("10.0.0.%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	waitForSink(sink, 2)
+
+	if n := sink.len(); n != 2 {
+		t.Fatalf("expected 2 events, found: %d", n)
+	}
+
+	for _, e := range sink.events {
+		if e.Route.Service != "foo" {
+			t.Errorf("expected foo route event, found: %s", e)
+		}
+	}
+
+	stop()
+
+	route.Service = "foo"
+	route.Address = "10.0.0.10"
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	if n := sink.len(); n != 2 {
+		t.Errorf("expected stopped sink to receive no events, found: %d", n)
+	}
+}
+
+func TestSinkMaxFailures(t *testing.T) {
+	table := newTable(SinkMaxFailures(2))
+	defer table.Close()
+
+	sink := &testSink{err: errors.New("sink error")}
+
+	if _, err := table.AddSink(sink); err != nil {
+		t.Fatalf("error adding sink: %s", err)
+	}
+
+	_, route := testSetup()
+
+	for i := 0; i < 5; i++ {
+		route.Address = fmt.Sprintf("10.0.0.%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	waitForSink(sink, 2)
+	time.Sleep(50 * time.Millisecond)
+
+	// the sink is removed after failing twice
+	if n := sink.len(); n != 2 {
+		t.Errorf("expected 2 events, found: %d", n)
+	}
+}
@@ -0,0 +1,96 @@
+package router
+
+import (
+	"sync"
+)
+
+// TableStats are routing table statistics
+type TableStats struct {
+	// Routes is the number of routes in the table
+	Routes int
+	// Delivered is the number of events delivered to watchers
+	Delivered uint64
+	// Filtered is the number of events filtered out by watchers
+	Filtered uint64
+	// Dropped is the number of events dropped for slow watchers
+	Dropped uint64
+	// Events is the number of emitted events per event type
+	Events map[EventType]uint64
+}
+
+// tableStats stores routing table event counters
+type tableStats struct {
+	sync.Mutex
+	delivered uint64
+	filtered  uint64
+	dropped   uint64
+	events    map[EventType]uint64
+}
+
+func newTableStats() *tableStats {
+	return &tableStats{
+		events: make(map[EventType]uint64),
+	}
+}
+
+func (s *tableStats) incDelivered() {
+	s.Lock()
+	s.delivered++
+	s.Unlock()
+}
+
+func (s *tableStats) incFiltered() {
+	s.Lock()
+	s.filtered++
+	s.Unlock()
+}
+
+func (s *tableStats) incDropped() {
+	s.Lock()
+	s.dropped++
+	s.Unlock()
+}
+
+func (s *tableStats) incEvent(typ EventType) {
+	s.Lock()
+	s.events[typ]++
+	s.Unlock()
+}
+
+// Stats returns the routing table statistics
+func (t *MemoryTable) Stats() TableStats {
+	t.RLock()
+	var routes int
+	for _, rmap := range t.routes {
+		routes += len(rmap)
+	}
+	t.RUnlock()
+
+	t.stats.Lock()
+	defer t.stats.Unlock()
+
+	events := make(map[EventType]uint64, len(t.stats.events))
+	for typ, count := range t.stats.events {
+		events[typ] = count
+	}
+
+	return TableStats{
+		Routes:    routes,
+		Delivered: t.stats.delivered,
+		Filtered:  t.stats.filtered,
+		Dropped:   t.stats.dropped,
+		Events:    events,
+	}
+}
+
+// ResetStats resets all the event counters to zero.
+// The route count is not a counter and is always reported as the current number of routes.
+func (t *MemoryTable) ResetStats() {
+	t.stats.Lock()
+	defer t.stats.Unlock()
+
+	t.stats.delivered = 0
+	t.stats.filtered = 0
+	t.stats.dropped = 0
+	t.stats.events = make(map[EventType]uint64)
+}
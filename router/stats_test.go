@@ -0,0 +1,115 @@
+package router
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResetStats(t *testing.T) {
+	table, route := testSetup()
+
+	w, err := table.Watch(WatchService("svc1"))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	for i, svc := range []string{"svc1", "svc2"} {
+		route.Service = svc
+		route.Address = fmt.Sprintf("dest.addr-%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	if _, err := w.Next(); err != nil {
+		t.Fatalf("error receiving event: %s", err)
+	}
+
+	stats := table.Stats()
+	if stats.Routes != 2 {
+		t.Errorf("incorrect number of routes. Expected: %d, found: %d", 2, stats.Routes)
+	}
+
+	if stats.Events[Create] != 2 {
+		t.Errorf("incorrect number of create events. Expected: %d, found: %d", 2, stats.Events[Create])
+	}
+
+	// delivery is counted right after the event has been queued
+	deadline := time.Now().Add(time.Second)
+	for stats.Delivered == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		stats = table.Stats()
+	}
+
+	if stats.Delivered == 0 {
+		t.Errorf("expected delivered events")
+	}
+
+	table.ResetStats()
+
+	stats = table.Stats()
+	if stats.Delivered != 0 || stats.Filtered != 0 || stats.Dropped != 0 || len(stats.Events) != 0 {
+		t.Errorf("counters not reset: %+v", stats)
+	}
+
+	// the route count is preserved
+	if stats.Routes != 2 {
+		t.Errorf("incorrect number of routes. Expected: %d, found: %d", 2, stats.Routes)
+	}
+
+	// subsequent reads start fresh
+	if err := table.Delete(route); err != nil {
+		t.Fatalf("error deleting route: %s", err)
+	}
+
+	stats = table.Stats()
+	if stats.Events[Delete] != 1 || stats.Events[Create] != 0 {
+		t.Errorf("incorrect event counters after reset: %+v", stats.Events)
+	}
+}
+
+func TestTableLatencyObserver(t *testing.T) {
+	var mu sync.Mutex
+	observed := make(map[string]int)
+
+	table := newTable(TableLatencyObserver(func(op string, d time.Duration) {
+		if d < 0 {
+			t.Errorf("negative %s duration: %s", op, d)
+		}
+		mu.Lock()
+		observed[op]++
+		mu.Unlock()
+	}))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	route.Metric = 100
+	if err := table.Update(route); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+
+	if _, err := table.Query(QueryService(route.Service)); err != nil {
+		t.Fatalf("error looking up routes: %s", err)
+	}
+
+	if err := table.Delete(route); err != nil {
+		t.Fatalf("error deleting route: %s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, op := range []string{"create", "update", "lookup", "delete"} {
+		if observed[op] != 1 {
+			t.Errorf("expected 1 %s observation, found: %d", op, observed[op])
+		}
+	}
+}
@@ -2,6 +2,11 @@ package router
 
 import (
 	"errors"
+	"hash/fnv"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -10,55 +15,506 @@ import (
 )
 
 var (
+	// DefaultDispatchQueueSize is the size of the event queue of each dispatch worker
+	DefaultDispatchQueueSize = 128
+	// DefaultActivationInterval is how often the scheduled routes are checked for activation
+	DefaultActivationInterval = time.Second
+	// DefaultShadowFactor is the default metric factor for shadowed routes
+	DefaultShadowFactor = 10.0
+	// DefaultMinMetric is the default minimum route metric
+	DefaultMinMetric int64 = 0
+	// DefaultMaxMetric is the default maximum route metric, the metric of unreachable routes
+	DefaultMaxMetric int64 = math.MaxInt64
 	// ErrRouteNotFound is returned when no route was found in the routing table
 	ErrRouteNotFound = errors.New("route not found")
 	// ErrDuplicateRoute is returned when the route already exists
 	ErrDuplicateRoute = errors.New("duplicate route")
+	// ErrTableClosed is returned when watching a closed routing table
+	ErrTableClosed = errors.New("table closed")
+	// ErrTooManyWatchers is returned when the table watcher limit has been reached
+	ErrTooManyWatchers = errors.New("too many watchers")
+	// ErrVersionMismatch is returned when the stored route version does not match the expected one
+	ErrVersionMismatch = errors.New("route version mismatch")
+	// ErrInvalidRoute is returned when the route is missing its service or address
+	ErrInvalidRoute = errors.New("invalid route")
+	// ErrImmutableField is returned when a route update changes an immutable route field
+	ErrImmutableField = errors.New("immutable route field")
+	// ErrInvalidInterval is returned when the migration or the registry sync interval is not positive
+	ErrInvalidInterval = errors.New("invalid interval")
 )
 
-// table is an in-memory routing table
-type table struct {
+// MemoryTable is an in-memory routing table. Besides implementing Table
+// it provides watching the table and the rest of the table features.
+type MemoryTable struct {
 	sync.RWMutex
+	opts TableOptions
 	// routes stores service routes
 	routes map[string]map[uint64]Route
 	// watchers stores table watchers
+	wmu      sync.RWMutex
 	watchers map[string]*tableWatcher
+	// ordered stores table watchers ordered by descending priority
+	ordered []*tableWatcher
+	// queues are the dispatch worker event queues
+	queues []chan *Event
+	// seq is the sequence number of the last emitted event
+	seq uint64
+	// version is the hash of the table contents
+	version uint64
+	// count is the number of routes in the table
+	count int
+	// ids maps the route ids to the route services
+	ids map[string]string
+	// pending stores the scheduled routes which have not been activated yet
+	pending map[uint64]Route
+	// scheduling is set once the route activation scheduler has been started
+	scheduling bool
+	// warming is set while the table is warming up
+	warming bool
+	// log retains the last emitted events
+	log []Event
+	// stamp is the timestamp of the last emitted event
+	stamp time.Time
+	// leases stores the route leases
+	leases map[leaseKey]lease
+	// requires maps the route ids to the ids of the routes they depend on
+	requires map[string]map[string]bool
+	// dependents maps the route ids to the ids of the routes depending on them
+	dependents map[string]map[string]bool
+	// pool interns the route strings
+	pool *stringPool
+	// baselines stores the metric baselines of routes
+	baselines map[uint64]int64
+	// decayed stores the metric decay accumulated for routes and not applied yet
+	decayed map[uint64]int64
+	// federation stores the peer networks visible from each network
+	federation map[string]map[string]bool
+	// stats stores the table event counters
+	stats *tableStats
+	// probes caches gateway probe results
+	probes *probeCache
+	// aliases maps service aliases to destination services
+	amu     sync.RWMutex
+	aliases map[string]string
+	// exit stops the table background processes
+	rmu  sync.Mutex
+	exit chan bool
+	once sync.Once
+	wg   sync.WaitGroup
+}
+
+// NewTable creates new in-memory routing table and returns it
+func NewTable(opts ...TableOption) *MemoryTable {
+	return newTable(opts...)
 }
 
 // newtable creates a new routing table and returns it
-func newTable(opts ...Option) *table {
-	return &table{
-		routes:   make(map[string]map[uint64]Route),
-		watchers: make(map[string]*tableWatcher),
+func newTable(opts ...TableOption) *MemoryTable {
+	options := TableOptions{
+		UpdateComparator:   routeUnchanged,
+		ShadowFactor:       DefaultShadowFactor,
+		ProbeCacheTTL:      DefaultProbeCacheTTL,
+		Codec:              NewJSONCodec(),
+		CostFunc:           MetricCost,
+		Clock:              time.Now,
+		Hasher:             hashRoute,
+		EventRetention:     DefaultEventRetention,
+		MinMetric:          DefaultMinMetric,
+		MaxMetric:          DefaultMaxMetric,
+		ActivationInterval: DefaultActivationInterval,
+	}
+
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// dynamic routes expire after a quarter of the route ttl by default
+	if options.DynamicRouteTTL <= 0 {
+		options.DynamicRouteTTL = options.RouteTTL / 4
+	}
+
+	if options.ActivationInterval <= 0 {
+		options.ActivationInterval = DefaultActivationInterval
+	}
+
+	t := &MemoryTable{
+		opts:       options,
+		routes:     make(map[string]map[uint64]Route),
+		watchers:   make(map[string]*tableWatcher),
+		pending:    make(map[uint64]Route),
+		ids:        make(map[string]string),
+		leases:     make(map[leaseKey]lease),
+		requires:   make(map[string]map[string]bool),
+		dependents: make(map[string]map[string]bool),
+		baselines:  make(map[uint64]int64),
+		decayed:    make(map[uint64]int64),
+		federation: make(map[string]map[string]bool),
+		stats:      newTableStats(),
+		probes:     newProbeCache(),
+		aliases:    make(map[string]string),
+		exit:       make(chan bool),
+	}
+
+	if options.StringInterning {
+		t.pool = newStringPool()
+	}
+
+	// the unknown immutable fields are logged with the table logger
+	t.opts.ImmutableFields = t.knownFields(options.ImmutableFields)
+
+	if options.DecayRate > 0 && options.DecayInterval > 0 {
+		t.run(func() {
+			t.decay(options.DecayRate, options.DecayInterval)
+		})
+	}
+
+	if options.RouteTTL > 0 || options.StaleThreshold > 0 || options.DynamicRouteTTL > 0 {
+		t.run(func() {
+			t.sweep(sweepInterval(options.RouteTTL, options.StaleThreshold, options.DynamicRouteTTL))
+		})
+	}
+
+	for i := 0; i < options.DispatchWorkers; i++ {
+		queue := make(chan *Event, DefaultDispatchQueueSize)
+		t.queues = append(t.queues, queue)
+		t.run(func() {
+			t.dispatch(queue)
+		})
+	}
+
+	return t
+}
+
+// run runs the table background process until the table is closed.
+// It does not start the process if the table has already been closed.
+func (t *MemoryTable) run(fn func()) {
+	t.rmu.Lock()
+	defer t.rmu.Unlock()
+
+	select {
+	case <-t.exit:
+		return
+	default:
+	}
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		fn()
+	}()
+}
+
+// Close stops the table background processes and all the table watchers.
+// It waits for the background processes to exit. Close is idempotent.
+func (t *MemoryTable) Close() error {
+	t.once.Do(func() {
+		t.rmu.Lock()
+		close(t.exit)
+		t.rmu.Unlock()
+
+		t.wg.Wait()
+
+		t.wmu.Lock()
+		defer t.wmu.Unlock()
+
+		// no events are being sent while we hold the lock
+		for id, w := range t.watchers {
+			w.Stop()
+			close(w.resChan)
+			delete(t.watchers, id)
+		}
+		t.ordered = nil
+	})
+
+	return nil
+}
+
+// observe reports the duration of the table operation started at the given time
+func (t *MemoryTable) observe(op string, start time.Time) {
+	t.opts.LatencyObserver(op, t.opts.Clock().Sub(start))
+}
+
+// normalize rescales the route metric using the configured normalizer
+// and clamps it into the table metric bounds
+func (t *MemoryTable) normalize(r Route) Route {
+	if t.opts.MetricNormalizer != nil {
+		r.Metric = t.opts.MetricNormalizer(r)
+	}
+
+	metric := r.Metric
+	switch {
+	case metric < t.opts.MinMetric:
+		r.Metric = t.opts.MinMetric
+	case metric > t.opts.MaxMetric:
+		r.Metric = t.opts.MaxMetric
+	default:
+		return r
 	}
+
+	t.logf(logger.WarnLevel, "Router clamping metric %d of route %s to service %s to %d", metric, r.Address, r.Service, r.Metric)
+
+	return r
 }
 
 // sendEvent sends events to all subscribed watchers
-func (t *table) sendEvent(e *Event) {
-	t.RLock()
-	defer t.RUnlock()
+func (t *MemoryTable) sendEvent(e *Event, pressure bool) {
+	t.wmu.RLock()
+	defer t.wmu.RUnlock()
 
-	if len(e.Id) == 0 {
-		e.Id = uuid.New().String()
+	for _, w := range t.ordered {
+		// skip the events already replayed to the watcher
+		if e.Seq <= w.from {
+			continue
+		}
+
+		e := w.transform(e)
+		if e == nil {
+			continue
+		}
+
+		if w.sampler != nil && !w.sampler.sample(e) {
+			continue
+		}
+
+		// level triggered watchers never block the dispatch
+		if w.opts.LevelTriggered {
+			w.collapse(e)
+			t.stats.incDelivered()
+			continue
+		}
+
+		var last bool
+		if w.opts.Limit > 0 {
+			var ok bool
+			if ok, last = w.reserve(e); !ok {
+				continue
+			}
+		}
+
+		switch {
+		case w.acks != nil:
+			t.deliverSync(w, e)
+		// under pressure drop the events of lower priority watchers right away
+		case pressure && w.opts.Priority < t.ordered[0].opts.Priority:
+			select {
+			case w.resChan <- e:
+				t.stats.incDelivered()
+				w.record(e)
+			case <-w.done:
+			default:
+				t.stats.incDropped()
+			}
+		default:
+			select {
+			case w.resChan <- e:
+				t.stats.incDelivered()
+				w.record(e)
+			case <-w.done:
+			// don't block forever
+			case <-time.After(time.Second):
+				t.stats.incDropped()
+			}
+		}
+
+		// limited watchers stop once they have delivered their last event
+		if last {
+			w.Stop()
+		}
 	}
+}
 
-	for _, w := range t.watchers {
+// deliverSync sends the event to synchronous watcher and blocks until the watcher
+// has acknowledged it, the watcher has been stopped or the table has been closed.
+func (t *MemoryTable) deliverSync(w *tableWatcher, e *Event) {
+	// filter the events right away so the watcher acknowledges only the events it receives
+	if !w.filter(e) {
+		t.stats.incFiltered()
+		return
+	}
+
+	w.smu.Lock()
+	defer w.smu.Unlock()
+
+	select {
+	case w.resChan <- e:
+		t.stats.incDelivered()
+		w.unacked++
+		w.record(e)
+	case <-w.done:
+		return
+	case <-t.exit:
+		return
+	}
+
+	w.await(t.exit)
+}
+
+// addWatcher registers the watcher keeping the watchers ordered by priority.
+// It must be called with the watchers lock held.
+func (t *MemoryTable) addWatcher(w *tableWatcher) {
+	t.watchers[w.id] = w
+	w.registered = true
+
+	i := sort.Search(len(t.ordered), func(i int) bool {
+		return t.ordered[i].opts.Priority < w.opts.Priority
+	})
+
+	t.ordered = append(t.ordered, nil)
+	copy(t.ordered[i+1:], t.ordered[i:])
+	t.ordered[i] = w
+}
+
+// removeWatcher deletes the watcher and reports whether it was registered.
+// It must be called with the watchers lock held.
+func (t *MemoryTable) removeWatcher(id string) bool {
+	delete(t.watchers, id)
+
+	for i, w := range t.ordered {
+		if w.id == id {
+			t.ordered = append(t.ordered[:i], t.ordered[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// emit stamps a new event of the given type and cause with the next sequence number
+// and sends it to all subscribed watchers. It must be called with the table lock held.
+func (t *MemoryTable) emit(typ EventType, r Route, cause Cause) {
+	// the events are replayed once the table has warmed up
+	if t.warming {
+		return
+	}
+
+	t.seq++
+	t.stats.incEvent(typ)
+
+	t.logf(logger.DebugLevel, "Router emitting %s for route: %s caused by %s", typ, r.Address, cause)
+
+	e := &Event{
+		Id:           uuid.New().String(),
+		Seq:          t.seq,
+		Type:         typ,
+		Timestamp:    t.opts.Clock(),
+		Route:        r,
+		TableVersion: t.version,
+		Cause:        cause,
+	}
+
+	t.stamp = e.Timestamp
+	t.retain(*e)
+
+	if len(t.queues) == 0 {
+		go t.sendEvent(e, false)
+		return
+	}
+
+	// events of the same route are always dispatched by the same worker to preserve their order
+	select {
+	case t.queues[t.hash(r)%uint64(len(t.queues))] <- e:
+	case <-t.exit:
+	}
+}
+
+// dispatch sends the queued events to the watchers until the table is closed
+func (t *MemoryTable) dispatch(queue chan *Event) {
+	for {
 		select {
-		case w.resChan <- e:
-		case <-w.done:
-		// don't block forever
-		case <-time.After(time.Second):
+		case e := <-queue:
+			// the queue backlog signals dispatch pressure
+			t.sendEvent(e, len(queue) > 0)
+		case <-t.exit:
+			return
 		}
 	}
 }
 
-// Create creates new route in the routing table
-func (t *table) Create(r Route) error {
+// logf logs the message with the table logger if the level is enabled
+func (t *MemoryTable) logf(level logger.Level, format string, v ...interface{}) {
+	l := t.opts.Logger
+	if l == nil {
+		l = logger.DefaultLogger
+	}
+	if logger.V(level, l) {
+		l.Logf(level, format, v...)
+	}
+}
+
+// hash returns the route hash using the table hasher
+func (t *MemoryTable) hash(r Route) uint64 {
+	return t.opts.Hasher(r)
+}
+
+// stateHash returns the hash of the route identity, its metric and status
+func (t *MemoryTable) stateHash(r Route) uint64 {
+	h := fnv.New64()
+	h.Write([]byte(strconv.FormatUint(t.hash(r), 10)))
+	h.Write([]byte(strconv.FormatInt(r.Metric, 10)))
+	h.Write([]byte(r.Status.String()))
+	return h.Sum64()
+}
+
+// setRoute stores the route under the given hash and updates the table version.
+// It must be called with the table lock held.
+func (t *MemoryTable) setRoute(sum uint64, r Route) {
+	r = t.internRoute(r)
+	if old, ok := t.routes[r.Service][sum]; ok {
+		t.version ^= t.stateHash(old)
+		t.releaseRoute(old)
+	} else {
+		t.count++
+	}
+	t.routes[r.Service][sum] = r
+	t.ids[r.Id] = r.Service
+	t.version ^= t.stateHash(r)
+}
+
+// deleteRoute deletes the route stored under the given hash and updates the table version.
+// It must be called with the table lock held.
+func (t *MemoryTable) deleteRoute(service string, sum uint64) {
+	if old, ok := t.routes[service][sum]; ok {
+		t.version ^= t.stateHash(old)
+		delete(t.routes[service], sum)
+		delete(t.ids, old.Id)
+		t.releaseRoute(old)
+		t.count--
+	}
+}
+
+// LookupById returns the route with the given id
+func (t *MemoryTable) LookupById(id string) (Route, bool) {
+	t.RLock()
+	defer t.RUnlock()
+
+	return t.lookupById(id)
+}
+
+// Version returns the hash of the table contents. Tables with the same
+// routes and metrics have the same version regardless of how they were built.
+func (t *MemoryTable) Version() uint64 {
+	t.RLock()
+	defer t.RUnlock()
+
+	return t.version
+}
+
+// create adds the route to the routing table. It must be called with the table lock held.
+func (t *MemoryTable) create(r Route, cause Cause) error {
 	service := r.Service
-	sum := r.Hash()
+	sum := t.hash(r)
 
-	t.Lock()
-	defer t.Unlock()
+	if t.scheduled(r) {
+		if _, ok := t.routes[service][sum]; ok {
+			return ErrDuplicateRoute
+		}
+		if _, ok := t.pending[sum]; ok {
+			return ErrDuplicateRoute
+		}
+		t.pending[sum] = r
+		t.startScheduler()
+		return nil
+	}
 
 	// check if there are any routes in the table for the route destination
 	if _, ok := t.routes[service]; !ok {
@@ -67,83 +523,414 @@ func (t *table) Create(r Route) error {
 
 	// add new route to the table for the route destination
 	if _, ok := t.routes[service][sum]; !ok {
-		t.routes[service][sum] = r
-		if logger.V(logger.DebugLevel, logger.DefaultLogger) {
-			logger.Debugf("Router emitting %s for route: %s", Create, r.Address)
-		}
-		go t.sendEvent(&Event{Type: Create, Timestamp: time.Now(), Route: r})
+		t.evict()
+		r.Id = routeId(sum)
+		r.Version = 1
+		r.LastSeen = t.opts.Clock()
+		t.setRoute(sum, r)
+		t.baselines[sum] = r.Metric
+		t.warnShadowed(service)
+		t.emit(Create, r, cause)
 		return nil
 	}
 
 	return ErrDuplicateRoute
 }
 
-// Delete deletes the route from the routing table
-func (t *table) Delete(r Route) error {
-	service := r.Service
-	sum := r.Hash()
+// Create creates new route in the routing table.
+// It returns ErrDuplicateRoute if the route already exists
+// and ErrLeaseHeld if the route is leased by another router.
+func (t *MemoryTable) Create(r Route) error {
+	if t.opts.LatencyObserver != nil {
+		defer t.observe("create", t.opts.Clock())
+	}
+
+	r = t.normalize(r)
 
 	t.Lock()
 	defer t.Unlock()
 
+	if err := t.checkLease(r); err != nil {
+		return err
+	}
+
+	return t.create(r, CauseDirect)
+}
+
+// remove deletes the route from the routing table. It must be called with the table lock held.
+func (t *MemoryTable) remove(r Route, cause Cause) error {
+	service := r.Service
+	sum := t.hash(r)
+
+	// scheduled routes are deleted without emitting any event
+	if _, ok := t.pending[sum]; ok {
+		delete(t.pending, sum)
+		return nil
+	}
+
 	if _, ok := t.routes[service]; !ok {
 		return ErrRouteNotFound
 	}
 
-	if _, ok := t.routes[service][sum]; !ok {
+	stored, ok := t.routes[service][sum]
+	if !ok {
 		return ErrRouteNotFound
 	}
 
-	delete(t.routes[service], sum)
-	if logger.V(logger.DebugLevel, logger.DefaultLogger) {
-		logger.Debugf("Router emitting %s for route: %s", Delete, r.Address)
-	}
-	go t.sendEvent(&Event{Type: Delete, Timestamp: time.Now(), Route: r})
+	t.deleteRoute(service, sum)
+	delete(t.baselines, sum)
+	delete(t.decayed, sum)
+	t.emit(Delete, r, cause)
+	t.cascade(stored.Id)
 
 	return nil
 }
 
-// Update updates routing table with the new route
-func (t *table) Update(r Route) error {
-	service := r.Service
-	sum := r.Hash()
+// Delete deletes the route from the routing table.
+// It returns ErrRouteNotFound if the route does not exist
+// and ErrLeaseHeld if the route is leased by another router.
+func (t *MemoryTable) Delete(r Route) error {
+	if t.opts.LatencyObserver != nil {
+		defer t.observe("delete", t.opts.Clock())
+	}
 
 	t.Lock()
 	defer t.Unlock()
 
+	if err := t.checkLease(r); err != nil {
+		return err
+	}
+
+	return t.remove(r, CauseDirect)
+}
+
+// DeleteIfExists deletes the route from the routing table if it exists.
+// Unlike Delete it does not treat a missing route as an error.
+func (t *MemoryTable) DeleteIfExists(r Route) error {
+	if err := t.Delete(r); err != nil && err != ErrRouteNotFound {
+		return err
+	}
+	return nil
+}
+
+// update updates the route in the routing table. It must be called with the table lock held.
+func (t *MemoryTable) update(r Route, cause Cause) error {
+	service := r.Service
+	sum := t.hash(r)
+
+	// the activation time of routes which are already active is ignored
+	if _, ok := t.routes[service][sum]; !ok && t.scheduled(r) {
+		t.pending[sum] = r
+		t.startScheduler()
+		return nil
+	}
+
+	// updating a scheduled route with no future activation time activates it
+	delete(t.pending, sum)
+	// the updated metric replaces the decayed one
+	delete(t.decayed, sum)
+
 	// check if the route destination has any routes in the table
 	if _, ok := t.routes[service]; !ok {
 		t.routes[service] = make(map[uint64]Route)
 	}
 
 	if _, ok := t.routes[service][sum]; !ok {
-		t.routes[service][sum] = r
-		if logger.V(logger.DebugLevel, logger.DefaultLogger) {
-			logger.Debugf("Router emitting %s for route: %s", Update, r.Address)
-		}
-		go t.sendEvent(&Event{Type: Update, Timestamp: time.Now(), Route: r})
+		t.evict()
+		r.Id = routeId(sum)
+		r.Version = 1
+		r.LastSeen = t.opts.Clock()
+		t.setRoute(sum, r)
+		t.baselines[sum] = r.Metric
+		t.warnShadowed(service)
+		t.emit(Update, r, cause)
 		return nil
 	}
 
-	// just update the route, but dont emit Update event
-	t.routes[service][sum] = r
+	old := t.routes[service][sum]
+	r.Id = old.Id
+	r.Version = old.Version + 1
+	r.LastSeen = t.opts.Clock()
+	t.setRoute(sum, r)
+	t.warnShadowed(service)
+
+	// only emit Update event if the route has changed
+	if !t.opts.UpdateComparator(old, r) {
+		t.emit(Update, r, cause)
+	}
 
 	return nil
 }
 
-// List returns a list of all routes in the table
-func (t *table) List() ([]Route, error) {
-	t.RLock()
-	defer t.RUnlock()
+// routeUnchanged returns true if the updated route keeps all the fields of the stored route
+// other than the ones managed by the table. Unlike the route hashes, which are equal for
+// the routes stored under the same hash, it detects the changes of the route state.
+func routeUnchanged(old, new Route) bool {
+	for field := range immutableFieldNames {
+		// the route id is assigned by the table
+		if field != "id" && fieldChanged(field, old, new) {
+			return false
+		}
+	}
+	return true
+}
+
+// adjust stores the route changed by the table itself rather than by its callers and emits
+// Update event. Unlike update it keeps the route last seen time so the automatic changes do
+// not keep the route alive. It must be called with the table lock held.
+func (t *MemoryTable) adjust(route Route, cause Cause) {
+	route.Version++
+	t.setRoute(t.hash(route), route)
+	t.emit(Update, route, cause)
+}
+
+// Update updates the route in the routing table.
+// It returns ErrRouteNotFound if the route does not exist, ErrLeaseHeld if the route
+// is leased by another router and ErrImmutableField if it changes an immutable field.
+func (t *MemoryTable) Update(r Route) error {
+	if t.opts.LatencyObserver != nil {
+		defer t.observe("update", t.opts.Clock())
+	}
+
+	r = t.normalize(r)
+
+	t.Lock()
+	defer t.Unlock()
+
+	sum := t.hash(r)
+	if _, ok := t.routes[r.Service][sum]; !ok {
+		if _, ok := t.pending[sum]; !ok {
+			// changing the immutable fields covered by the hash is not a new route
+			if err := t.checkImmutable(r); err != nil {
+				return err
+			}
+			return ErrRouteNotFound
+		}
+	}
+
+	if err := t.checkLease(r); err != nil {
+		return err
+	}
+
+	r = t.mergeMetadata(r)
+	if err := t.checkImmutable(r); err != nil {
+		return err
+	}
+
+	return t.update(r, CauseDirect)
+}
+
+// Upsert creates the route if it does not exist or updates it otherwise, atomically.
+// It returns Create if the route has been created and Update if it has been updated.
+// Creating or updating the route leased by another router returns ErrLeaseHeld and
+// changing an immutable field returns ErrImmutableField.
+func (t *MemoryTable) Upsert(r Route) (EventType, error) {
+	if t.opts.LatencyObserver != nil {
+		defer t.observe("upsert", t.opts.Clock())
+	}
 
+	r = t.normalize(r)
+
+	t.Lock()
+	defer t.Unlock()
+
+	sum := t.hash(r)
+	if _, ok := t.routes[r.Service][sum]; !ok {
+		if _, ok := t.pending[sum]; !ok {
+			if err := t.checkLease(r); err != nil {
+				return Create, err
+			}
+			// changing the immutable fields covered by the hash is not a new route
+			if err := t.checkImmutable(r); err != nil {
+				return Update, err
+			}
+			return Create, t.create(r, CauseDirect)
+		}
+	}
+
+	if err := t.checkLease(r); err != nil {
+		return Update, err
+	}
+
+	r = t.mergeMetadata(r)
+	if err := t.checkImmutable(r); err != nil {
+		return Update, err
+	}
+
+	return Update, t.update(r, CauseDirect)
+}
+
+// CompareAndUpdate updates the route only if the version of the stored route matches
+// the expected version. It returns ErrVersionMismatch if the route has been modified
+// since the expected version was read, ErrRouteNotFound if the route does not exist,
+// ErrLeaseHeld if the route is leased by another router and ErrImmutableField if
+// the update changes an immutable field.
+func (t *MemoryTable) CompareAndUpdate(expectedVersion uint64, r Route) error {
+	r = t.normalize(r)
+
+	t.Lock()
+	defer t.Unlock()
+
+	stored, ok := t.routes[r.Service][t.hash(r)]
+	if !ok {
+		// changing the immutable fields covered by the hash is not a new route
+		if err := t.checkImmutable(r); err != nil {
+			return err
+		}
+		return ErrRouteNotFound
+	}
+
+	if stored.Version != expectedVersion {
+		return ErrVersionMismatch
+	}
+
+	if err := t.checkLease(r); err != nil {
+		return err
+	}
+
+	r = t.mergeMetadata(r)
+	if err := t.checkImmutable(r); err != nil {
+		return err
+	}
+
+	return t.update(r, CauseDirect)
+}
+
+// Set converges the routing table to the given set of routes under a single lock.
+// It deletes the routes which are not in the set, creates the missing ones and updates
+// the changed ones, emitting only the events needed to converge. Unlike recreating
+// the routes, unchanged routes are preserved as they are. The scheduled routes which
+// are not in the set are deleted before they are activated. The routes leased by another
+// router, see AcquireLease, are neither deleted, created nor updated.
+func (t *MemoryTable) Set(routes []Route) {
+	desired := make(map[uint64]Route, len(routes))
+	for _, r := range routes {
+		desired[t.hash(r)] = t.normalize(r)
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	for sum, route := range t.pending {
+		if _, ok := desired[sum]; !ok && t.checkLease(route) == nil {
+			delete(t.pending, sum)
+		}
+	}
+
+	for service, rmap := range t.routes {
+		for sum, route := range rmap {
+			if _, ok := desired[sum]; ok || t.checkLease(route) != nil {
+				continue
+			}
+			t.remove(route, CauseSync)
+		}
+		if len(rmap) == 0 {
+			delete(t.routes, service)
+		}
+	}
+
+	for sum, r := range desired {
+		if t.checkLease(r) != nil {
+			continue
+		}
+
+		// the changed scheduled routes are rescheduled or activated
+		if pending, ok := t.pending[sum]; ok {
+			if !reflect.DeepEqual(pending, r) {
+				t.update(r, CauseSync)
+			}
+			continue
+		}
+
+		stored, ok := t.routes[r.Service][sum]
+		if !ok {
+			t.create(r, CauseSync)
+			continue
+		}
+
+		// skip the routes which have not changed at all
+		r.Id = stored.Id
+		r.Version = stored.Version
+		r.LastSeen = stored.LastSeen
+		if reflect.DeepEqual(stored, r) {
+			continue
+		}
+
+		t.update(r, CauseSync)
+	}
+}
+
+// list returns all the routes in the table. It must be called with the table lock held.
+func (t *MemoryTable) list() []Route {
 	var routes []Route
 	for _, rmap := range t.routes {
 		for _, route := range rmap {
 			routes = append(routes, route)
 		}
 	}
+	return routes
+}
+
+// List returns a list of all routes in the table
+func (t *MemoryTable) List() ([]Route, error) {
+	t.RLock()
+	defer t.RUnlock()
 
-	return routes, nil
+	return t.list(), nil
+}
+
+// shadowedRoutes returns the routes whose metric is worse than the best
+// metric of the routes to the same destination by more than factor
+func shadowedRoutes(routes map[uint64]Route, factor float64) []Route {
+	// best metric for each service network
+	best := make(map[string]int64)
+
+	for _, route := range routes {
+		routeKey := route.Service + "@" + route.Network
+		if metric, ok := best[routeKey]; !ok || route.Metric < metric {
+			best[routeKey] = route.Metric
+		}
+	}
+
+	var results []Route
+	for _, route := range routes {
+		metric := best[route.Service+"@"+route.Network]
+		// don't let zero metrics shadow everything else
+		if metric < 1 {
+			metric = 1
+		}
+		if float64(route.Metric) > float64(metric)*factor {
+			results = append(results, route)
+		}
+	}
+
+	return results
+}
+
+// warnShadowed logs a warning for each shadowed route of the service.
+// It must be called with the table lock held.
+func (t *MemoryTable) warnShadowed(service string) {
+	if !t.opts.ShadowWarnings {
+		return
+	}
+
+	for _, route := range shadowedRoutes(t.routes[service], t.opts.ShadowFactor) {
+		t.logf(logger.WarnLevel, "Router route %s via %s to service %s is shadowed with metric %d", route.Address, route.Gateway, route.Service, route.Metric)
+	}
+}
+
+// ShadowedRoutes returns the routes which are effectively never selected because their
+// metric is worse than the best metric for their destination by more than the shadow factor
+func (t *MemoryTable) ShadowedRoutes() []Route {
+	t.RLock()
+	defer t.RUnlock()
+
+	var routes []Route
+	for _, rmap := range t.routes {
+		routes = append(routes, shadowedRoutes(rmap, t.opts.ShadowFactor)...)
+	}
+
+	return routes
 }
 
 // isMatch checks if the route matches given query options
@@ -233,7 +1020,11 @@ func findRoutes(routes map[uint64]Route, address, gateway, network, router strin
 }
 
 // Lookup queries routing table and returns all routes that match the lookup query
-func (t *table) Query(q ...QueryOption) ([]Route, error) {
+func (t *MemoryTable) Query(q ...QueryOption) ([]Route, error) {
+	if t.opts.LatencyObserver != nil {
+		defer t.observe("lookup", t.opts.Clock())
+	}
+
 	t.RLock()
 	defer t.RUnlock()
 
@@ -249,10 +1040,11 @@ func (t *table) Query(q ...QueryOption) ([]Route, error) {
 	}
 
 	if opts.Service != "*" {
-		if _, ok := t.routes[opts.Service]; !ok {
+		service := t.resolve(opts.Service)
+		if _, ok := t.routes[service]; !ok {
 			return nil, ErrRouteNotFound
 		}
-		return findRoutes(t.routes[opts.Service], opts.Address, opts.Gateway, opts.Network, opts.Router, opts.Strategy), nil
+		return findRoutes(t.routes[service], opts.Address, opts.Gateway, opts.Network, opts.Router, opts.Strategy), nil
 	}
 
 	// search through all destinations
@@ -263,36 +1055,258 @@ func (t *table) Query(q ...QueryOption) ([]Route, error) {
 	return results, nil
 }
 
+// Federate makes the routes of the peer network visible to lookups in the given network.
+// Networks are isolated unless explicitly federated; federation is not symmetric.
+func (t *MemoryTable) Federate(network, peer string) {
+	t.Lock()
+	defer t.Unlock()
+
+	if _, ok := t.federation[network]; !ok {
+		t.federation[network] = make(map[string]bool)
+	}
+	t.federation[network][peer] = true
+}
+
+// Unfederate removes the federation of the peer network with the given network
+func (t *MemoryTable) Unfederate(network, peer string) {
+	t.Lock()
+	defer t.Unlock()
+
+	delete(t.federation[network], peer)
+}
+
+// LookupInNetwork returns the routes to the destination service in the given network
+// and in the networks federated with it. It returns ErrRouteNotFound if there are none.
+func (t *MemoryTable) LookupInNetwork(service, network string) ([]Route, error) {
+	t.RLock()
+	defer t.RUnlock()
+
+	var results []Route
+	for _, route := range t.routes[t.resolve(service)] {
+		if route.Network == network || t.federation[network][route.Network] {
+			results = append(results, route)
+		}
+	}
+
+	if len(results) == 0 {
+		return nil, ErrRouteNotFound
+	}
+
+	return results, nil
+}
+
+// checkWatchers checks if a new watcher with the given options can be registered
+// without exceeding the watcher limits. It must be called with the watchers lock held.
+func (t *MemoryTable) checkWatchers(opts WatchOptions) error {
+	if t.opts.MaxWatchers <= 0 && t.opts.MaxServiceWatchers <= 0 {
+		return nil
+	}
+
+	var total, service int
+	for _, w := range t.watchers {
+		// skip the stopped watchers which have not been removed yet
+		select {
+		case <-w.done:
+			continue
+		default:
+		}
+
+		total++
+		if opts.Service != "*" && w.info().Service == opts.Service {
+			service++
+		}
+	}
+
+	if t.opts.MaxWatchers > 0 && total >= t.opts.MaxWatchers {
+		return ErrTooManyWatchers
+	}
+
+	if t.opts.MaxServiceWatchers > 0 && service >= t.opts.MaxServiceWatchers {
+		return ErrTooManyWatchers
+	}
+
+	return nil
+}
+
 // Watch returns routing table entry watcher
-func (t *table) Watch(opts ...WatchOption) (Watcher, error) {
+func (t *MemoryTable) Watch(opts ...WatchOption) (Watcher, error) {
+	w, err := t.watch(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// the hook runs outside of the table locks
+	if t.opts.OnWatcherAdded != nil {
+		t.opts.OnWatcherAdded(w.info())
+	}
+
+	return w, nil
+}
+
+// watchOptions returns the watch options and the parsed watch criteria
+func watchOptions(opts ...WatchOption) (WatchOptions, watchCriteria, error) {
 	// by default watch everything
 	wopts := WatchOptions{
 		Service: "*",
+		Router:  "*",
+		Network: "*",
 	}
 
 	for _, o := range opts {
 		o(&wopts)
 	}
 
+	if wopts.Sample && !(wopts.SampleFraction > 0 && wopts.SampleFraction <= 1) {
+		return wopts, watchCriteria{}, ErrInvalidSampleFraction
+	}
+
+	criteria, err := parseCriteria(wopts)
+	return wopts, criteria, err
+}
+
+// catchup returns the events catching up from the snapshot to the table routes.
+// It must be called with the table lock held.
+func (t *MemoryTable) catchup(since, routes []Route) []*Event {
+	events := diff(since, routes, t.hash)
+	for _, e := range events {
+		e.TableVersion = t.version
+	}
+	return events
+}
+
+// newWatcher creates a new table watcher with the given queue size.
+// The watcher is deleted from the table once it's stopped.
+func (t *MemoryTable) newWatcher(wopts WatchOptions, criteria watchCriteria, size int) *tableWatcher {
 	w := &tableWatcher{
-		id:      uuid.New().String(),
-		opts:    wopts,
-		resChan: make(chan *Event, 10),
-		done:    make(chan struct{}),
+		id:       uuid.New().String(),
+		opts:     wopts,
+		resChan:  make(chan *Event, size),
+		done:     make(chan struct{}),
+		stats:    t.stats,
+		resolve:  t.resolve,
+		criteria: criteria,
+		hash:     t.hash,
+		latest:   make(map[uint64]*Event),
+		inflight: make(map[uint64]*delivery),
+		seen:     t.opts.Clock(),
+		emitted:  t.emitted,
+		notify:   make(chan struct{}, 1),
+	}
+
+	if wopts.Delivery == DeliverySync && !wopts.LevelTriggered {
+		// the acknowledgements of all the buffered events never block
+		w.acks = make(chan struct{}, size)
+	}
+
+	if wopts.Sample && wopts.SampleFraction < 1 {
+		w.sampler = newSampler(wopts.SampleFraction, wopts.SampleByMetric)
+	}
+
+	if wopts.Resync > 0 && wopts.Map == nil {
+		w.view = make(map[uint64]Route)
 	}
 
 	// when the watcher is stopped delete it
 	go func() {
 		<-w.done
-		t.Lock()
-		delete(t.watchers, w.id)
-		t.Unlock()
+		t.wmu.Lock()
+		registered := w.registered
+		// limited watchers close their channel so the consumers can drain the delivered events
+		if t.removeWatcher(w.id) && w.opts.Limit > 0 {
+			close(w.resChan)
+		}
+		t.wmu.Unlock()
+
+		if registered && t.opts.OnWatcherRemoved != nil {
+			t.opts.OnWatcherRemoved(w.info())
+		}
 	}()
 
+	return w
+}
+
+// startResync starts resyncing the watcher unless it is not resynced
+func (t *MemoryTable) startResync(w *tableWatcher) {
+	if w.view == nil {
+		return
+	}
+
+	t.run(func() {
+		t.resync(w, w.opts.Resync)
+	})
+}
+
+// watch creates and registers a new table watcher
+func (t *MemoryTable) watch(opts ...WatchOption) (*tableWatcher, error) {
+	wopts, criteria, err := watchOptions(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var catchup []*Event
+
+	// compute the catch up events and register the watcher under the
+	// table lock so that no mutation happens in between
+	t.RLock()
+	defer t.RUnlock()
+
+	switch {
+	case wopts.Resume:
+		events, _, err := t.changedSince(wopts.ResumeFrom)
+		if err != nil {
+			return nil, err
+		}
+		for i := range events {
+			catchup = append(catchup, &events[i])
+		}
+	case wopts.Since != nil:
+		catchup = t.catchup(wopts.Since, t.list())
+	}
+
+	w := t.newWatcher(wopts, criteria, len(catchup)+10)
+	if wopts.Resume || wopts.Since != nil {
+		w.from = t.seq
+	}
+
+	// the watcher position starts after the events it resumes from
+	w.position = t.seq
+	if wopts.Resume {
+		w.position = wopts.ResumeFrom
+	}
+
+	// the view of resynced watcher starts from the snapshot it catches up from
+	if w.view != nil {
+		if wopts.Since != nil && !wopts.Resume {
+			w.seed(wopts.Since)
+		} else {
+			w.seed(t.list())
+			w.from = t.seq
+		}
+	}
+
 	// save the watcher
-	t.Lock()
-	t.watchers[w.id] = w
-	t.Unlock()
+	t.wmu.Lock()
+	defer t.wmu.Unlock()
+
+	select {
+	case <-t.exit:
+		w.Stop()
+		return nil, ErrTableClosed
+	default:
+	}
+
+	if err := t.checkWatchers(wopts); err != nil {
+		w.Stop()
+		return nil, err
+	}
+
+	t.addWatcher(w)
+
+	for _, e := range catchup {
+		w.send(e)
+	}
+
+	t.startResync(w)
 
 	return w, nil
 }
@@ -1,11 +1,20 @@
 package router
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
 	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/micro/go-micro/v2/logger"
 )
 
-func testSetup() (*table, Route) {
+func testSetup() (*MemoryTable, Route) {
 	table := newTable()
 
 	route := Route{
@@ -64,6 +73,27 @@ func TestDelete(t *testing.T) {
 	}
 }
 
+func TestDeleteIfExists(t *testing.T) {
+	table, route := testSetup()
+
+	if err := table.Create(route); err != nil {
+		t.Errorf("error adding route: %s", err)
+	}
+
+	if err := table.DeleteIfExists(route); err != nil {
+		t.Errorf("error deleting route: %s", err)
+	}
+
+	if routes, _ := table.List(); len(routes) != 0 {
+		t.Errorf("expected route to be deleted, found %d routes", len(routes))
+	}
+
+	// deleting missing route is not an error
+	if err := table.DeleteIfExists(route); err != nil {
+		t.Errorf("error deleting missing route: %s", err)
+	}
+}
+
 func TestUpdate(t *testing.T) {
 	table, route := testSetup()
 
@@ -78,11 +108,79 @@ func TestUpdate(t *testing.T) {
 		t.Errorf("error updating route: %s", err)
 	}
 
-	// this should add a new route
+	// updating non-existent route must error
 	route.Service = "rand.dest"
 
-	if err := table.Update(route); err != nil {
-		t.Errorf("error updating route: %s", err)
+	if err := table.Update(route); err != ErrRouteNotFound {
+		t.Errorf("error updating route. Expected: %s, found: %v", ErrRouteNotFound, err)
+	}
+
+	if routes, _ := table.Query(QueryService(route.Service)); len(routes) != 0 {
+		t.Errorf("expected no routes to be added, found: %d", len(routes))
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	table, route := testSetup()
+	defer table.Close()
+
+	typ, err := table.Upsert(route)
+	if err != nil {
+		t.Fatalf("error upserting route: %s", err)
+	}
+	if typ != Create {
+		t.Errorf("expected %s, found: %s", Create, typ)
+	}
+
+	route.Metric = 200
+	typ, err = table.Upsert(route)
+	if err != nil {
+		t.Fatalf("error upserting route: %s", err)
+	}
+	if typ != Update {
+		t.Errorf("expected %s, found: %s", Update, typ)
+	}
+
+	routes, _ := table.List()
+	if len(routes) != 1 || routes[0].Metric != 200 {
+		t.Fatalf("expected updated route, found: %+v", routes)
+	}
+
+	// exactly one of the concurrent upserts of the same route creates it
+	route.Address = "dest.addr-concurrent"
+
+	var wg sync.WaitGroup
+	types := make(chan EventType, 10)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(metric int64) {
+			defer wg.Done()
+			r := route
+			r.Metric = metric
+			typ, err := table.Upsert(r)
+			if err != nil {
+				t.Errorf("error upserting route: %s", err)
+			}
+			types <- typ
+		}(int64(i))
+	}
+
+	wg.Wait()
+	close(types)
+
+	var creates int
+	for typ := range types {
+		if typ == Create {
+			creates++
+		}
+	}
+	if creates != 1 {
+		t.Errorf("expected 1 create, found: %d", creates)
+	}
+
+	if routes, _ := table.List(); len(routes) != 2 {
+		t.Errorf("expected 2 routes, found: %d", len(routes))
 	}
 }
 
@@ -288,3 +386,954 @@ func TestQuery(t *testing.T) {
 		t.Errorf("incorrect number of routes returned. Expected: %d, found: %d", 1, len(routes))
 	}
 }
+
+// testClock is a fake clock safe for use by the table goroutines
+type testClock struct {
+	sync.Mutex
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Now()}
+}
+
+// Now returns the current fake time
+func (c *testClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+// Add advances the fake time
+func (c *testClock) Add(d time.Duration) {
+	c.Lock()
+	c.now = c.now.Add(d)
+	c.Unlock()
+}
+
+// testLogger records the logged messages
+type testLogger struct {
+	sync.Mutex
+	messages []string
+}
+
+func (l *testLogger) Init(...logger.Option) error {
+	return nil
+}
+
+func (l *testLogger) Options() logger.Options {
+	return logger.Options{Level: logger.WarnLevel}
+}
+
+func (l *testLogger) Fields(map[string]interface{}) logger.Logger {
+	return l
+}
+
+func (l *testLogger) Log(level logger.Level, v ...interface{}) {
+	l.Logf(level, "%s", fmt.Sprint(v...))
+}
+
+func (l *testLogger) Logf(level logger.Level, format string, v ...interface{}) {
+	l.Lock()
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+	l.Unlock()
+}
+
+func (l *testLogger) String() string {
+	return "test"
+}
+
+func TestMetricBounds(t *testing.T) {
+	log := new(testLogger)
+
+	table := newTable(TableMetricBounds(1, 1000), TableLogger(log))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	metrics := map[string]int64{
+		"dest.addr-negative": -5,
+		"dest.addr-huge":     1 << 40,
+		"dest.addr-valid":    500,
+	}
+
+	for address, metric := range metrics {
+		route.Address = address
+		route.Metric = metric
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	expected := map[string]int64{
+		"dest.addr-negative": 1,
+		"dest.addr-huge":     1000,
+		"dest.addr-valid":    500,
+	}
+
+	routes, _ := table.List()
+	for _, r := range routes {
+		if r.Metric != expected[r.Address] {
+			t.Errorf("expected route %s metric: %d, found: %d", r.Address, expected[r.Address], r.Metric)
+		}
+	}
+
+	// the updates are clamped too
+	route.Address = "dest.addr-valid"
+	route.Metric = 5000
+	if err := table.Update(route); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+
+	routes, _ = table.Query(QueryAddress("dest.addr-valid"))
+	if len(routes) != 1 || routes[0].Metric != 1000 {
+		t.Errorf("expected clamped route update, found: %+v", routes)
+	}
+
+	log.Lock()
+	defer log.Unlock()
+
+	var warnings int
+	for _, msg := range log.messages {
+		if strings.Contains(msg, "clamping metric") {
+			warnings++
+		}
+	}
+	if warnings != 3 {
+		t.Errorf("expected 3 clamping warnings, found: %d in %v", warnings, log.messages)
+	}
+}
+
+func TestMetricNormalizer(t *testing.T) {
+	// src.ms reports latency in milliseconds, src.us in microseconds;
+	// both are scaled to a cost between 1 and 100
+	normalize := func(r Route) int64 {
+		metric := r.Metric
+		if r.Router == "src.us" {
+			metric = metric / 1000
+		}
+		if metric < 1 {
+			return 1
+		}
+		if metric > 100 {
+			return 100
+		}
+		return metric
+	}
+
+	table := newTable(MetricNormalizer(normalize))
+
+	route := Route{
+		Service: "dest.svc",
+		Address: "dest.addr1",
+		Router:  "src.ms",
+		Link:    "det.link",
+		Metric:  20,
+	}
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	route.Address = "dest.addr2"
+	route.Router = "src.us"
+	route.Metric = 20000
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	routes, err := table.Query(QueryService("dest.svc"))
+	if err != nil {
+		t.Fatalf("error looking up routes: %s", err)
+	}
+
+	if len(routes) != 2 {
+		t.Fatalf("incorrect number of routes returned. Expected: %d, found: %d", 2, len(routes))
+	}
+
+	if routes[0].Metric != routes[1].Metric {
+		t.Errorf("expected comparable metrics, found: %d and %d", routes[0].Metric, routes[1].Metric)
+	}
+
+	// metric updates are normalized too
+	route.Metric = 500000
+
+	if err := table.Update(route); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+
+	routes, err = table.Query(QueryService("dest.svc"), QueryAddress("dest.addr2"))
+	if err != nil {
+		t.Fatalf("error looking up routes: %s", err)
+	}
+
+	if routes[0].Metric != 100 {
+		t.Errorf("incorrect metric. Expected: %d, found: %d", 100, routes[0].Metric)
+	}
+}
+
+// collectEvents reads events from the watcher until no event arrives within the timeout
+func collectEvents(w Watcher, timeout time.Duration) []*Event {
+	ch, _ := w.Chan()
+
+	var events []*Event
+	for {
+		select {
+		case e := <-ch:
+			events = append(events, e)
+		case <-time.After(timeout):
+			return events
+		}
+	}
+}
+
+func TestUpdateComparator(t *testing.T) {
+	// ignore metric changes smaller than 10
+	comparator := func(old, new Route) bool {
+		diff := old.Metric - new.Metric
+		if diff < 0 {
+			diff = -diff
+		}
+		return old.Hash() == new.Hash() && diff < 10
+	}
+
+	table := newTable(UpdateComparator(comparator))
+	_, route := testSetup()
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	if events := collectEvents(w, 100*time.Millisecond); len(events) != 1 {
+		t.Fatalf("incorrect number of events. Expected: %d, found: %d", 1, len(events))
+	}
+
+	// within the threshold: no event
+	route.Metric = 15
+
+	if err := table.Update(route); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+
+	if events := collectEvents(w, 100*time.Millisecond); len(events) != 0 {
+		t.Fatalf("incorrect number of events. Expected: %d, found: %d", 0, len(events))
+	}
+
+	// above the threshold: update event
+	route.Metric = 100
+
+	if err := table.Update(route); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+
+	events := collectEvents(w, 100*time.Millisecond)
+	if len(events) != 1 {
+		t.Fatalf("incorrect number of events. Expected: %d, found: %d", 1, len(events))
+	}
+
+	if events[0].Type != Update || events[0].Route.Metric != 100 {
+		t.Errorf("incorrect event: %s %d", events[0].Type, events[0].Route.Metric)
+	}
+
+	// the stored route is updated regardless of the comparator
+	routes, err := table.Query(QueryService(route.Service))
+	if err != nil {
+		t.Fatalf("error looking up routes: %s", err)
+	}
+
+	if routes[0].Metric != 100 {
+		t.Errorf("incorrect metric. Expected: %d, found: %d", 100, routes[0].Metric)
+	}
+}
+
+func TestDefaultUpdateComparator(t *testing.T) {
+	// the network is left out of the route identity so that it can be updated
+	hash := func(r Route) uint64 {
+		return (&Route{Service: r.Service, Address: r.Address}).Hash()
+	}
+
+	table := newTable(TableHasher(hash), DispatchWorkers(1))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	// the unchanged route emits no event
+	if err := table.Update(route); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+
+	// the changes of the route state and of the fields left out of the hash emit events
+	route.Metric = 100
+	if err := table.Update(route); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+	route.Network = "other.network"
+	if err := table.Update(route); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+
+	events := collectEvents(w, 100*time.Millisecond)
+	if len(events) != 3 {
+		t.Fatalf("incorrect number of events. Expected: %d, found: %d", 3, len(events))
+	}
+
+	if events[1].Type != Update || events[1].Route.Metric != 100 || events[2].Route.Network != "other.network" {
+		t.Errorf("incorrect update events: %v", events[1:])
+	}
+}
+
+func TestShadowedRoutes(t *testing.T) {
+	_, route := testSetup()
+
+	metrics := []int64{10, 20, 500}
+
+	setup := func(opts ...TableOption) *MemoryTable {
+		table := newTable(opts...)
+		for i, metric := range metrics {
+			route.Address = fmt.Sprintf("dest.addr-%d", i)
+			route.Metric = metric
+			if err := table.Create(route); err != nil {
+				t.Fatalf("error adding route: %s", err)
+			}
+		}
+		return table
+	}
+
+	routes := setup().ShadowedRoutes()
+	if len(routes) != 1 {
+		t.Fatalf("incorrect number of shadowed routes. Expected: %d, found: %d", 1, len(routes))
+	}
+
+	if routes[0].Metric != 500 {
+		t.Errorf("incorrect shadowed route. Expected metric: %d, found: %d", 500, routes[0].Metric)
+	}
+
+	// a stricter factor shadows the second route too
+	if routes := setup(ShadowFactor(1.5), ShadowWarnings(true)).ShadowedRoutes(); len(routes) != 2 {
+		t.Errorf("incorrect number of shadowed routes. Expected: %d, found: %d", 2, len(routes))
+	}
+}
+
+func TestCompareAndUpdate(t *testing.T) {
+	table, route := testSetup()
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	routes, err := table.Query(QueryService(route.Service))
+	if err != nil {
+		t.Fatalf("error looking up routes: %s", err)
+	}
+
+	read := routes[0]
+	if read.Version != 1 {
+		t.Fatalf("incorrect route version. Expected: %d, found: %d", 1, read.Version)
+	}
+
+	// concurrent modifications based on the same read version
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(metric int64) {
+			defer wg.Done()
+			r := read
+			r.Metric = metric
+			errs <- table.CompareAndUpdate(read.Version, r)
+		}(int64(100 + i))
+	}
+
+	wg.Wait()
+	close(errs)
+
+	var applied, mismatched int
+	for err := range errs {
+		switch err {
+		case nil:
+			applied++
+		case ErrVersionMismatch:
+			mismatched++
+		default:
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+
+	if applied != 1 || mismatched != 9 {
+		t.Errorf("expected exactly one update to be applied. Applied: %d, mismatched: %d", applied, mismatched)
+	}
+
+	routes, err = table.Query(QueryService(route.Service))
+	if err != nil {
+		t.Fatalf("error looking up routes: %s", err)
+	}
+
+	if routes[0].Version != 2 {
+		t.Errorf("incorrect route version. Expected: %d, found: %d", 2, routes[0].Version)
+	}
+
+	// stale version is rejected
+	if err := table.CompareAndUpdate(read.Version, read); err != ErrVersionMismatch {
+		t.Errorf("expected error: %s, found: %v", ErrVersionMismatch, err)
+	}
+
+	// missing route
+	route.Service = "missing.svc"
+	if err := table.CompareAndUpdate(1, route); err != ErrRouteNotFound {
+		t.Errorf("expected error: %s, found: %v", ErrRouteNotFound, err)
+	}
+}
+
+func TestSet(t *testing.T) {
+	// emit updates on metric changes
+	table := newTable(UpdateComparator(func(old, new Route) bool {
+		return old.Hash() == new.Hash() && old.Metric == new.Metric
+	}))
+	_, route := testSetup()
+
+	var routes []Route
+	for i := 0; i < 3; i++ {
+		route.Address = fmt.Sprintf("dest.addr-%d", i)
+		routes = append(routes, route)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	// wait for the create events to be dispatched
+	collectEvents(w, 100*time.Millisecond)
+
+	// drop the first route, change the last one and add a new one
+	changed := routes[2]
+	changed.Metric = 100
+
+	added := route
+	added.Address = "dest.addr-3"
+
+	table.Set([]Route{routes[1], changed, added})
+
+	events := collectEvents(w, 100*time.Millisecond)
+	if len(events) != 3 {
+		t.Fatalf("incorrect number of events. Expected: %d, found: %d", 3, len(events))
+	}
+
+	expected := map[string]EventType{
+		routes[0].Address: Delete,
+		changed.Address:   Update,
+		added.Address:     Create,
+	}
+
+	for _, event := range events {
+		typ, ok := expected[event.Route.Address]
+		if !ok {
+			t.Errorf("unexpected event %s for route %s", event.Type, event.Route.Address)
+			continue
+		}
+		if typ != event.Type {
+			t.Errorf("incorrect event for route %s. Expected: %s, found: %s", event.Route.Address, typ, event.Type)
+		}
+	}
+
+	// the unchanged route is preserved
+	unchanged, err := table.Query(QueryAddress(routes[1].Address))
+	if err != nil {
+		t.Fatalf("error looking up routes: %s", err)
+	}
+
+	if len(unchanged) != 1 || unchanged[0].Version != 1 {
+		t.Errorf("unchanged route was modified: %+v", unchanged)
+	}
+
+	list, err := table.List()
+	if err != nil {
+		t.Fatalf("error listing routes: %s", err)
+	}
+
+	if len(list) != 3 {
+		t.Errorf("incorrect number of routes. Expected: %d, found: %d", 3, len(list))
+	}
+}
+
+func TestLookupInNetwork(t *testing.T) {
+	table, route := testSetup()
+
+	nets := []string{"net1", "net2", "net2"}
+
+	for i, net := range nets {
+		route.Network = net
+		route.Address = fmt.Sprintf("dest.addr-%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	// networks are isolated
+	routes, err := table.LookupInNetwork(route.Service, "net1")
+	if err != nil {
+		t.Fatalf("error looking up routes: %s", err)
+	}
+
+	if len(routes) != 1 || routes[0].Network != "net1" {
+		t.Errorf("expected only net1 routes, found: %+v", routes)
+	}
+
+	if _, err := table.LookupInNetwork(route.Service, "net3"); err != ErrRouteNotFound {
+		t.Errorf("expected error: %s, found: %v", ErrRouteNotFound, err)
+	}
+
+	// federate net2 into net1
+	table.Federate("net1", "net2")
+
+	routes, err = table.LookupInNetwork(route.Service, "net1")
+	if err != nil {
+		t.Fatalf("error looking up routes: %s", err)
+	}
+
+	if len(routes) != 3 {
+		t.Errorf("incorrect number of routes returned. Expected: %d, found: %d", 3, len(routes))
+	}
+
+	// federation is not symmetric
+	routes, err = table.LookupInNetwork(route.Service, "net2")
+	if err != nil {
+		t.Fatalf("error looking up routes: %s", err)
+	}
+
+	if len(routes) != 2 {
+		t.Errorf("incorrect number of routes returned. Expected: %d, found: %d", 2, len(routes))
+	}
+
+	table.Unfederate("net1", "net2")
+
+	routes, err = table.LookupInNetwork(route.Service, "net1")
+	if err != nil {
+		t.Fatalf("error looking up routes: %s", err)
+	}
+
+	if len(routes) != 1 {
+		t.Errorf("incorrect number of routes returned. Expected: %d, found: %d", 1, len(routes))
+	}
+}
+
+func TestDispatchWorkers(t *testing.T) {
+	table := newTable(DispatchWorkers(4))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	// route events are delivered in order
+	for i := 0; i < 5; i++ {
+		route.Metric = int64(i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+		if err := table.Delete(route); err != nil {
+			t.Fatalf("error deleting route: %s", err)
+		}
+	}
+
+	var last uint64
+	for i := 0; i < 10; i++ {
+		event, err := w.Next()
+		if err != nil {
+			t.Fatalf("error receiving event: %s", err)
+		}
+		if event.Seq <= last {
+			t.Fatalf("events out of order: %d after %d", event.Seq, last)
+		}
+		last = event.Seq
+	}
+}
+
+func TestWatchPriority(t *testing.T) {
+	table := newTable(DispatchWorkers(1))
+	defer table.Close()
+
+	high, err := table.Watch(WatchPriority(10))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer high.Stop()
+
+	// the low priority watcher never reads its events
+	low, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer low.Stop()
+
+	const count = 500
+
+	received := make(chan int)
+	go func() {
+		var n int
+		for n < count {
+			if _, err := high.Next(); err != nil {
+				break
+			}
+			n++
+		}
+		received <- n
+	}()
+
+	_, route := testSetup()
+
+	for i := 0; i < count; i++ {
+		route.Address = fmt.Sprintf("10.0.0.%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	select {
+	case n := <-received:
+		if n != count {
+			t.Errorf("expected %d events delivered to high priority watcher, found: %d", count, n)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("high priority watcher did not keep up")
+	}
+
+	if low.Len() >= count {
+		t.Errorf("expected low priority watcher to drop events")
+	}
+
+	if stats := table.Stats(); stats.Dropped == 0 {
+		t.Errorf("expected dropped events")
+	}
+}
+
+func benchmarkDispatch(b *testing.B, watchers int) {
+	table := newTable(DispatchWorkers(4))
+	defer table.Close()
+
+	for i := 0; i < watchers; i++ {
+		w, err := table.Watch()
+		if err != nil {
+			b.Fatalf("error creating watcher: %s", err)
+		}
+		defer w.Stop()
+
+		// drain the watcher
+		go func() {
+			for {
+				if _, err := w.Next(); err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	_, route := testSetup()
+
+	b.ResetTimer()
+
+	// the watchers are drained concurrently so the mutations are measured
+	// including the backpressure of the full worker queues
+	for i := 0; i < b.N; i++ {
+		route.Address = fmt.Sprintf("dest.addr-%d", i)
+		table.Create(route)
+	}
+
+	// wait for the dispatch workers to drain their queues
+	for _, queue := range table.queues {
+		for len(queue) > 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func BenchmarkDispatch1(b *testing.B)   { benchmarkDispatch(b, 1) }
+func BenchmarkDispatch10(b *testing.B)  { benchmarkDispatch(b, 10) }
+func BenchmarkDispatch100(b *testing.B) { benchmarkDispatch(b, 100) }
+
+func TestEventTableVersion(t *testing.T) {
+	table, route := testSetup()
+
+	if v := table.Version(); v != 0 {
+		t.Errorf("incorrect empty table version. Expected: %d, found: %d", 0, v)
+	}
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	created := table.Version()
+
+	route.Address = "dest.addr2"
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	events := collectEvents(w, 100*time.Millisecond)
+	if len(events) != 2 {
+		t.Fatalf("incorrect number of events. Expected: %d, found: %d", 2, len(events))
+	}
+
+	for _, event := range events {
+		switch event.Seq {
+		case 1:
+			if event.TableVersion != created {
+				t.Errorf("incorrect table version of the first event. Expected: %d, found: %d", created, event.TableVersion)
+			}
+		case 2:
+			// the consumer has caught up with the table
+			if event.TableVersion != table.Version() {
+				t.Errorf("incorrect table version of the last event. Expected: %d, found: %d", table.Version(), event.TableVersion)
+			}
+		}
+	}
+
+	// deleting the route restores the previous version
+	if err := table.Delete(route); err != nil {
+		t.Fatalf("error deleting route: %s", err)
+	}
+
+	if v := table.Version(); v != created {
+		t.Errorf("incorrect table version. Expected: %d, found: %d", created, v)
+	}
+}
+
+func TestTableHasher(t *testing.T) {
+	hasher := func(r Route) uint64 {
+		sum := sha256.Sum256([]byte(r.Service + r.Address + r.Gateway + r.Network + r.Router + r.Link))
+		return binary.BigEndian.Uint64(sum[:8])
+	}
+
+	_, route := testSetup()
+
+	routes := make([]Route, 3)
+	for i := range routes {
+		routes[i] = route
+		routes[i].Address = fmt.Sprintf("dest.addr-%d", i)
+	}
+
+	build := func(routes []Route, opts ...TableOption) *MemoryTable {
+		table := newTable(opts...)
+		for _, r := range routes {
+			if err := table.Create(r); err != nil {
+				t.Fatalf("error adding route: %s", err)
+			}
+		}
+		return table
+	}
+
+	def := build(routes)
+	custom := build(routes, TableHasher(hasher))
+
+	if def.Version() == custom.Version() {
+		t.Errorf("expected the hasher to change the table version")
+	}
+
+	// the version does not depend on the order the routes were added in
+	reversed := build([]Route{routes[2], routes[1], routes[0]}, TableHasher(hasher))
+	if v := reversed.Version(); v != custom.Version() {
+		t.Errorf("incorrect table version. Expected: %d, found: %d", custom.Version(), v)
+	}
+
+	// changing and restoring the metric restores the version
+	version := custom.Version()
+
+	changed := routes[0]
+	changed.Metric = 100
+	if err := custom.Update(changed); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+
+	if custom.Version() == version {
+		t.Errorf("expected the metric change to change the table version")
+	}
+
+	if err := custom.Update(routes[0]); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+
+	if v := custom.Version(); v != version {
+		t.Errorf("incorrect table version. Expected: %d, found: %d", version, v)
+	}
+}
+
+func TestClose(t *testing.T) {
+	goroutines := runtime.NumGoroutine()
+
+	table := newTable(MetricDecay(1, 10*time.Millisecond), DispatchWorkers(4))
+	_, route := testSetup()
+
+	var watchers []Watcher
+	for i := 0; i < 3; i++ {
+		w, err := table.Watch()
+		if err != nil {
+			t.Fatalf("error creating watcher: %s", err)
+		}
+		watchers = append(watchers, w)
+	}
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	// close concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := table.Close(); err != nil {
+				t.Errorf("error closing table: %s", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, w := range watchers {
+		// drain any buffered events
+		for {
+			if _, err := w.Next(); err != nil {
+				if err != ErrWatcherStopped {
+					t.Errorf("expected error: %s, found: %s", ErrWatcherStopped, err)
+				}
+				break
+			}
+		}
+
+		ch, _ := w.Chan()
+		for range ch {
+		}
+	}
+
+	if _, err := table.Watch(); err != ErrTableClosed {
+		t.Errorf("expected error: %s, found: %v", ErrTableClosed, err)
+	}
+
+	// wait for the watcher cleanup goroutines to exit
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Errorf("goroutines leaked. Expected: %d, found: %d", goroutines, n)
+	}
+
+	// closing again is a noop
+	if err := table.Close(); err != nil {
+		t.Errorf("error closing table: %s", err)
+	}
+}
+
+func TestMetadataMergeMode(t *testing.T) {
+	_, route := testSetup()
+	route.Metadata = map[string]string{"region": "eu", "version": "1", "zone": "a"}
+
+	for _, mode := range []MetadataMode{MetadataReplace, MetadataMerge} {
+		table := newTable(MetadataMergeMode(mode))
+
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+
+		// update the version and drop the zone
+		update := route
+		update.Metadata = map[string]string{"version": "2", "zone": ""}
+		if err := table.Update(update); err != nil {
+			t.Fatalf("error updating route: %s", err)
+		}
+
+		routes, err := table.Query(QueryService(route.Service))
+		if err != nil {
+			t.Fatalf("error looking up routes: %s", err)
+		}
+
+		expected := update.Metadata
+		if mode == MetadataMerge {
+			expected = map[string]string{"region": "eu", "version": "2"}
+		}
+
+		if md := routes[0].Metadata; !reflect.DeepEqual(md, expected) {
+			t.Errorf("incorrect metadata. Expected: %v, found: %v", expected, md)
+		}
+
+		table.Close()
+	}
+}
+
+func TestLookupById(t *testing.T) {
+	table, route := testSetup()
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	routes, err := table.List()
+	if err != nil {
+		t.Fatalf("error listing routes: %s", err)
+	}
+
+	id := routes[0].Id
+	if len(id) == 0 {
+		t.Fatalf("expected route id to be assigned")
+	}
+
+	route.Metric = 100
+	route.Metadata = map[string]string{"foo": "bar"}
+	if err := table.Update(route); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+
+	found, ok := table.LookupById(id)
+	if !ok {
+		t.Fatalf("route %s not found", id)
+	}
+
+	if found.Id != id || found.Metric != 100 || found.Address != route.Address {
+		t.Errorf("incorrect route found by id: %+v", found)
+	}
+
+	if err := table.Delete(route); err != nil {
+		t.Fatalf("error deleting route: %s", err)
+	}
+
+	if _, ok := table.LookupById(id); ok {
+		t.Errorf("expected deleted route not to be found")
+	}
+
+	if _, ok := table.LookupById("missing"); ok {
+		t.Errorf("expected missing route not to be found")
+	}
+}
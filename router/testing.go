@@ -0,0 +1,34 @@
+package router
+
+import (
+	"sort"
+	"time"
+)
+
+// CollectEvents watches the table for the given duration and returns all the events
+// matching the watch options sorted by their sequence number. It returns error if the table
+// can't be watched. It's meant to remove the boilerplate of asserting on routing behaviour in tests.
+func CollectEvents(t WatchableTable, d time.Duration, opts ...WatchOption) ([]*Event, error) {
+	w, err := t.Watch(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	timer := time.AfterFunc(d, w.Stop)
+	defer timer.Stop()
+
+	var events []*Event
+	for {
+		event, err := w.Next()
+		if err != nil {
+			break
+		}
+		events = append(events, event)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Seq < events[j].Seq
+	})
+
+	return events, nil
+}
@@ -0,0 +1,44 @@
+package router
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestCollectEvents(t *testing.T) {
+	table, route := testSetup()
+	defer table.Close()
+
+	go func() {
+		// wait for the watcher to be created
+		time.Sleep(20 * time.Millisecond)
+		for i := 0; i < 3; i++ {
+			route.Service = fmt.Sprintf("svc-%d", i%2)
+			route.Address = fmt.Sprintf("dest.addr-%d", i)
+			table.Create(route)
+		}
+	}()
+
+	events, err := CollectEvents(table, 200*time.Millisecond, WatchService("svc-0"))
+	if err != nil {
+		t.Fatalf("error collecting events: %s", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("incorrect number of events. Expected: %d, found: %d", 2, len(events))
+	}
+
+	for i, event := range events {
+		if event.Route.Service != "svc-0" {
+			t.Errorf("incorrect event service. Expected: %s, found: %s", "svc-0", event.Route.Service)
+		}
+		if i > 0 && events[i-1].Seq >= event.Seq {
+			t.Errorf("events not sorted by sequence: %d >= %d", events[i-1].Seq, event.Seq)
+		}
+	}
+
+	// a table which can't be watched is reported
+	if _, err := CollectEvents(table, 0, WatchAddressCIDR("invalid")); err == nil {
+		t.Errorf("expected error watching table with invalid CIDR")
+	}
+}
@@ -0,0 +1,138 @@
+package router
+
+import (
+	"time"
+)
+
+// sweep periodically expires the routes until the table is closed
+func (t *MemoryTable) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.exit:
+			return
+		case <-ticker.C:
+			t.expire()
+		}
+	}
+}
+
+// sweepInterval returns the sweeper interval, running twice per the shortest threshold
+func sweepInterval(thresholds ...time.Duration) time.Duration {
+	var interval time.Duration
+	for _, d := range thresholds {
+		if d > 0 && (interval <= 0 || d < interval) {
+			interval = d
+		}
+	}
+	return interval / 2
+}
+
+// ttl returns the ttl of the route class, zero if the routes never expire
+func (t *MemoryTable) ttl(r Route) time.Duration {
+	switch r.Class {
+	case Static:
+		return 0
+	case Dynamic:
+		return t.opts.DynamicRouteTTL
+	default:
+		return t.opts.RouteTTL
+	}
+}
+
+// expire deletes the routes which have not been seen within their class ttl and drains
+// the healthy routes which have not been seen within the stale threshold. Static routes
+// never age.
+func (t *MemoryTable) expire() {
+	t.Lock()
+	defer t.Unlock()
+
+	now := t.opts.Clock()
+
+	for _, rmap := range t.routes {
+		for _, route := range rmap {
+			if route.Class == Static {
+				continue
+			}
+
+			age := now.Sub(route.LastSeen)
+
+			if ttl := t.ttl(route); ttl > 0 && age > ttl {
+				t.remove(route, CauseExpiry)
+				continue
+			}
+
+			if t.opts.StaleThreshold > 0 && age > t.opts.StaleThreshold && route.Status == Healthy {
+				route.Status = Draining
+				t.adjust(route, CauseHealth)
+			}
+		}
+	}
+}
+
+// evict deletes the least recently seen route if the table is full, preferring the dynamic
+// routes over the learned ones. Static routes are never evicted. It must be called with
+// the table lock held.
+func (t *MemoryTable) evict() {
+	if t.opts.MaxRoutes <= 0 || t.count < t.opts.MaxRoutes {
+		return
+	}
+
+	var oldest Route
+	var found bool
+
+	for _, rmap := range t.routes {
+		for _, route := range rmap {
+			if route.Class == Static {
+				continue
+			}
+			// dynamic routes are evicted before the learned ones
+			if found && oldest.Class != route.Class {
+				if oldest.Class == Dynamic {
+					continue
+				}
+				if route.Class == Dynamic {
+					oldest = route
+					continue
+				}
+			}
+			if !found || route.LastSeen.Before(oldest.LastSeen) {
+				oldest = route
+				found = true
+			}
+		}
+	}
+
+	if found {
+		t.remove(oldest, CauseEviction)
+	}
+}
+
+// Refresh marks the routes to the service address as seen, extending their ttl,
+// without emitting any event. It returns ErrRouteNotFound if there are no routes
+// to the service address.
+func (t *MemoryTable) Refresh(service, address string) error {
+	t.Lock()
+	defer t.Unlock()
+
+	now := t.opts.Clock()
+
+	var found bool
+	for sum, route := range t.routes[t.resolve(service)] {
+		if route.Address != address {
+			continue
+		}
+		found = true
+
+		route.LastSeen = now
+		t.setRoute(sum, route)
+	}
+
+	if !found {
+		return ErrRouteNotFound
+	}
+
+	return nil
+}
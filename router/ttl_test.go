@@ -0,0 +1,401 @@
+package router
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestRouteExpiry(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	table := newTable(RouteTTL(time.Hour), TableClock(clock))
+	defer table.Close()
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error watching table: %s", err)
+	}
+	defer w.Stop()
+
+	_, route := testSetup()
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	// refreshing the route keeps it alive
+	now = now.Add(45 * time.Minute)
+	if err := table.Update(route); err != nil {
+		t.Fatalf("error updating route: %s", err)
+	}
+
+	now = now.Add(45 * time.Minute)
+	table.expire()
+
+	if routes, _ := table.List(); len(routes) != 1 {
+		t.Fatalf("expected refreshed route to be kept, found %d routes", len(routes))
+	}
+
+	now = now.Add(30 * time.Minute)
+	table.expire()
+
+	if routes, _ := table.List(); len(routes) != 0 {
+		t.Fatalf("expected expired route to be deleted, found %d routes", len(routes))
+	}
+
+	events := collectEvents(w, 100*time.Millisecond)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, found: %d", len(events))
+	}
+
+	for _, e := range events {
+		switch e.Type {
+		case Create:
+			if e.Cause != CauseDirect {
+				t.Errorf("expected create caused by %s, found: %s", CauseDirect, e.Cause)
+			}
+		case Delete:
+			if e.Cause != CauseExpiry {
+				t.Errorf("expected delete caused by %s, found: %s", CauseExpiry, e.Cause)
+			}
+		default:
+			t.Errorf("unexpected event: %s", e)
+		}
+	}
+}
+
+func TestRouteEviction(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	table := newTable(MaxRoutes(2), TableClock(clock))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	for _, addr := range []string{"10.0.0.1", "10.0.0.2"} {
+		route.Address = addr
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+		now = now.Add(time.Second)
+	}
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error watching table: %s", err)
+	}
+	defer w.Stop()
+
+	route.Address = "10.0.0.3"
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	routes, err := table.List()
+	if err != nil {
+		t.Fatalf("error listing routes: %s", err)
+	}
+
+	if len(routes) != 2 {
+		t.Fatalf("expected 2 routes, found: %d", len(routes))
+	}
+
+	for _, r := range routes {
+		if r.Address == "10.0.0.1" {
+			t.Errorf("expected least recently seen route to be evicted")
+		}
+	}
+
+	var evicted bool
+	for _, e := range collectEvents(w, 100*time.Millisecond) {
+		if e.Type != Delete {
+			continue
+		}
+		if e.Cause != CauseEviction {
+			t.Errorf("expected delete caused by %s, found: %s", CauseEviction, e.Cause)
+		}
+		if e.Route.Address != "10.0.0.1" {
+			t.Errorf("expected evicted route 10.0.0.1, found: %s", e.Route.Address)
+		}
+		evicted = true
+	}
+
+	if !evicted {
+		t.Errorf("expected eviction event")
+	}
+}
+
+func TestEventCause(t *testing.T) {
+	e := &Event{Seq: 1, Type: Delete, Route: Route{Service: "dest.svc", Address: "dest.addr"}, Cause: CauseExpiry}
+
+	if s := e.String(); s != "1 delete dest.svc dest.addr caused by expiry" {
+		t.Errorf("unexpected event string: %s", s)
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatalf("error encoding event: %s", err)
+	}
+
+	var decoded Event
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("error decoding event: %s", err)
+	}
+
+	if decoded.Cause != CauseExpiry {
+		t.Errorf("expected cause %s, found: %s", CauseExpiry, decoded.Cause)
+	}
+}
+
+func TestStaleThreshold(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	table := newTable(StaleThreshold(time.Minute), RouteTTL(time.Hour), TableClock(clock))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error watching table: %s", err)
+	}
+	defer w.Stop()
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	status := func() (RouteStatus, bool) {
+		routes, _ := table.List()
+		if len(routes) == 0 {
+			return Healthy, false
+		}
+		return routes[0].Status, true
+	}
+
+	now = now.Add(30 * time.Second)
+	table.expire()
+
+	if s, ok := status(); !ok || s != Healthy {
+		t.Fatalf("expected healthy route, found: %s", s)
+	}
+
+	now = now.Add(time.Minute)
+	table.expire()
+
+	if s, ok := status(); !ok || s != Draining {
+		t.Fatalf("expected draining route, found: %s", s)
+	}
+
+	// draining the route again emits no event
+	table.expire()
+
+	now = now.Add(time.Hour)
+	table.expire()
+
+	if _, ok := status(); ok {
+		t.Fatalf("expected expired route to be deleted")
+	}
+
+	events := collectEvents(w, 100*time.Millisecond)
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, found: %d", len(events))
+	}
+
+	for _, e := range events {
+		switch e.Type {
+		case Create:
+		case Update:
+			if e.Route.Status != Draining || e.Cause != CauseHealth {
+				t.Errorf("expected draining update caused by %s, found: %s", CauseHealth, e)
+			}
+		case Delete:
+			if e.Cause != CauseExpiry {
+				t.Errorf("expected delete caused by %s, found: %s", CauseExpiry, e.Cause)
+			}
+		default:
+			t.Errorf("unexpected event: %s", e)
+		}
+	}
+
+	if interval := sweepInterval(0, time.Minute); interval != 30*time.Second {
+		t.Errorf("incorrect sweep interval: %s", interval)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	table := newTable(RouteTTL(time.Minute), TableClock(clock))
+	defer table.Close()
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error watching table: %s", err)
+	}
+	defer w.Stop()
+
+	_, route := testSetup()
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	version := table.Version()
+
+	for i := 0; i < 3; i++ {
+		now = now.Add(45 * time.Second)
+		if err := table.Refresh(route.Service, route.Address); err != nil {
+			t.Fatalf("error refreshing route: %s", err)
+		}
+		table.expire()
+	}
+
+	if routes, _ := table.List(); len(routes) != 1 {
+		t.Fatalf("expected refreshed route to be kept, found %d routes", len(routes))
+	}
+
+	if v := table.Version(); v != version {
+		t.Errorf("expected refresh to keep the table version")
+	}
+
+	// only the create event has been emitted
+	if events := collectEvents(w, 100*time.Millisecond); len(events) != 1 || events[0].Type != Create {
+		t.Errorf("expected single create event, found: %v", events)
+	}
+
+	if err := table.Refresh(route.Service, "missing.addr"); err != ErrRouteNotFound {
+		t.Errorf("expected error: %s, found: %v", ErrRouteNotFound, err)
+	}
+}
+
+func TestRouteClass(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	table := newTable(RouteTTL(time.Hour), TableClock(clock))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	classes := map[RouteClass]string{
+		Static:  "10.0.0.1",
+		Learned: "10.0.0.2",
+		Dynamic: "10.0.0.3",
+	}
+
+	for class, address := range classes {
+		r := route
+		r.Address = address
+		r.Class = class
+		if err := table.Create(r); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	addresses := func() map[string]bool {
+		routes, _ := table.List()
+		result := make(map[string]bool)
+		for _, r := range routes {
+			result[r.Address] = true
+		}
+		return result
+	}
+
+	// dynamic routes expire after a quarter of the route ttl
+	now = now.Add(30 * time.Minute)
+	table.expire()
+
+	if got := addresses(); len(got) != 2 || got[classes[Dynamic]] {
+		t.Errorf("expected dynamic route to expire, found: %v", got)
+	}
+
+	// learned routes expire after the route ttl, static routes never do
+	now = now.Add(time.Hour)
+	table.expire()
+
+	if got := addresses(); len(got) != 1 || !got[classes[Static]] {
+		t.Errorf("expected only static route to be kept, found: %v", got)
+	}
+}
+
+func TestEvictRouteClass(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	table := newTable(MaxRoutes(2), TableClock(clock))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	create := func(address string, class RouteClass) {
+		r := route
+		r.Address = address
+		r.Class = class
+		if err := table.Create(r); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+		now = now.Add(time.Second)
+	}
+
+	create("static", Static)
+	create("learned", Learned)
+	create("dynamic", Dynamic)
+
+	// the static route is kept even though it is the least recently seen one
+	routes, _ := table.List()
+	for _, r := range routes {
+		if r.Address == "learned" {
+			t.Errorf("expected learned route to be evicted")
+		}
+	}
+
+	// the dynamic routes are evicted before the learned ones
+	create("learned-2", Learned)
+	routes, _ = table.List()
+	for _, r := range routes {
+		if r.Address == "dynamic" {
+			t.Errorf("expected dynamic route to be evicted")
+		}
+	}
+	if len(routes) != 2 {
+		t.Errorf("expected 2 routes, found: %d", len(routes))
+	}
+}
+
+func TestAutomaticUpdateExpiry(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	table := newTable(RouteTTL(time.Hour), TableClock(clock))
+	defer table.Close()
+
+	_, route := testSetup()
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	// tagging the route is not a refresh so it does not keep the route alive
+	now = now.Add(45 * time.Minute)
+	if err := table.Tag(route.Service, route.Address, "canary"); err != nil {
+		t.Fatalf("error tagging route: %s", err)
+	}
+
+	routes, _ := table.List()
+	if len(routes) != 1 || routes[0].Version != 2 || !routes[0].LastSeen.Equal(now.Add(-45*time.Minute)) {
+		t.Fatalf("expected tagged route to keep its last seen time, found: %v", routes)
+	}
+
+	now = now.Add(30 * time.Minute)
+	table.expire()
+
+	if routes, _ := table.List(); len(routes) != 0 {
+		t.Errorf("expected tagged route to expire, found %d routes", len(routes))
+	}
+}
@@ -0,0 +1,36 @@
+package router
+
+import (
+	"errors"
+)
+
+// ErrWarmingUp is returned when warming up the table which is already warming up
+var ErrWarmingUp = errors.New("table is warming up")
+
+// WarmUp runs the loader with the table events suppressed. Once the loader returns
+// the watchers receive only the events which converge the table from its state before
+// the warm up to its final state, rather than every intermediate change, regardless
+// of whether the loader has failed. All the table mutations made while warming up are
+// suppressed, including the ones not made by the loader.
+func (t *MemoryTable) WarmUp(fn func(Table) error) error {
+	t.Lock()
+	if t.warming {
+		t.Unlock()
+		return ErrWarmingUp
+	}
+	t.warming = true
+	before := t.list()
+	t.Unlock()
+
+	err := fn(t)
+
+	t.Lock()
+	defer t.Unlock()
+
+	t.warming = false
+	for _, e := range diff(before, t.list(), t.hash) {
+		t.emit(e.Type, e.Route, CauseSync)
+	}
+
+	return err
+}
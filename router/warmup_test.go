@@ -0,0 +1,77 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestWarmUp(t *testing.T) {
+	table := newTable()
+	defer table.Close()
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error watching table: %s", err)
+	}
+	defer w.Stop()
+
+	_, route := testSetup()
+
+	loadErr := errors.New("load error")
+
+	err = table.WarmUp(func(tb Table) error {
+		for i := 0; i < 10; i++ {
+			route.Address = fmt.Sprintf("10.0.0.%d", i)
+			if err := tb.Create(route); err != nil {
+				return err
+			}
+		}
+
+		// the intermediate changes are not replayed
+		route.Metric = 100
+		if err := tb.Update(route); err != nil {
+			return err
+		}
+		if err := tb.Delete(route); err != nil {
+			return err
+		}
+
+		if err := table.WarmUp(func(Table) error { return nil }); err != ErrWarmingUp {
+			t.Errorf("expected error: %s, found: %v", ErrWarmingUp, err)
+		}
+
+		return loadErr
+	})
+
+	if err != loadErr {
+		t.Errorf("expected error: %s, found: %v", loadErr, err)
+	}
+
+	events := collectEvents(w, 100*time.Millisecond)
+	if len(events) != 9 {
+		t.Fatalf("expected 9 events, found: %d", len(events))
+	}
+
+	for _, e := range events {
+		if e.Type != Create || e.Cause != CauseSync {
+			t.Errorf("expected create caused by %s, found: %s", CauseSync, e)
+		}
+		if e.Route.Address == route.Address {
+			t.Errorf("expected deleted route not to be replayed")
+		}
+		if e.TableVersion != table.Version() {
+			t.Errorf("expected event of the final table version, found: %d", e.TableVersion)
+		}
+	}
+
+	// events are emitted again after warming up
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	if events := collectEvents(w, 100*time.Millisecond); len(events) != 1 {
+		t.Errorf("expected 1 event, found: %d", len(events))
+	}
+}
@@ -1,7 +1,9 @@
 package router
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -9,6 +11,10 @@ import (
 var (
 	// ErrWatcherStopped is returned when routing table watcher has been stopped
 	ErrWatcherStopped = errors.New("watcher stopped")
+	// ErrLevelTriggered is returned when requesting the events channel of level triggered watcher
+	ErrLevelTriggered = errors.New("level triggered watcher has no events channel")
+	// ErrInvalidSampleFraction is returned when the sample fraction is not greater than 0 and at most 1
+	ErrInvalidSampleFraction = errors.New("invalid sample fraction")
 )
 
 // EventType defines routing table event
@@ -37,16 +43,90 @@ func (t EventType) String() string {
 	}
 }
 
+// Cause defines what caused the routing table event
+type Cause int
+
+const (
+	// CauseDirect is a direct table mutation
+	CauseDirect Cause = iota
+	// CauseSync is a table sync or import
+	CauseSync
+	// CauseExpiry is a route expiry
+	CauseExpiry
+	// CauseEviction is a route eviction from a full table
+	CauseEviction
+	// CauseHealth is an automatic route health adjustment
+	CauseHealth
+	// CauseSchedule is a scheduled route activation
+	CauseSchedule
+	// CauseResync is a watcher resync correcting missed events
+	CauseResync
+	// CauseDependency is a cascade from the route the route depends on
+	CauseDependency
+	// CauseMigration is a route migration step
+	CauseMigration
+	// CauseCompaction is a table compaction deleting shadowed routes
+	CauseCompaction
+)
+
+var causes = map[Cause]string{
+	CauseDirect:     "direct",
+	CauseSync:       "sync",
+	CauseExpiry:     "expiry",
+	CauseEviction:   "eviction",
+	CauseHealth:     "health",
+	CauseSchedule:   "schedule",
+	CauseResync:     "resync",
+	CauseDependency: "dependency",
+	CauseMigration:  "migration",
+	CauseCompaction: "compaction",
+}
+
+// String returns human readable event cause
+func (c Cause) String() string {
+	if s, ok := causes[c]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// MarshalText encodes the cause as its name
+func (c Cause) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText decodes the cause from its name
+func (c *Cause) UnmarshalText(b []byte) error {
+	for cause, s := range causes {
+		if s == string(b) {
+			*c = cause
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown cause: %s", b)
+}
+
 // Event is returned by a call to Next on the watcher.
 type Event struct {
 	// Unique id of the event
 	Id string
+	// Seq is the table sequence number of the event
+	Seq uint64
 	// Type defines type of event
 	Type EventType
 	// Timestamp is event timestamp
 	Timestamp time.Time
 	// Route is table route
 	Route Route
+	// TableVersion is the table version right after the event was applied
+	TableVersion uint64
+	// Cause is what caused the event
+	Cause Cause
+}
+
+// String returns human readable event
+func (e *Event) String() string {
+	return fmt.Sprintf("%d %s %s %s caused by %s", e.Seq, e.Type, e.Route.Service, e.Route.Address, e.Cause)
 }
 
 // Watcher defines routing table watcher interface
@@ -56,6 +136,10 @@ type Watcher interface {
 	Next() (*Event, error)
 	// Chan returns event channel
 	Chan() (<-chan *Event, error)
+	// Len returns the number of buffered events
+	Len() int
+	// UpdateFilter changes what routes the watcher watches
+	UpdateFilter(...WatchOption) error
 	// Stop stops watcher
 	Stop()
 }
@@ -68,8 +152,54 @@ type WatchOption func(*WatchOptions)
 type WatchOptions struct {
 	// Service allows to watch specific service routes
 	Service string
+	// Router allows to watch routes learned from specific router
+	Router string
+	// Network allows to watch routes in specific network
+	Network string
+	// Expr is a filter expression routes have to match
+	Expr string
+	// AddressCIDR is the subnet the route addresses have to be in
+	AddressCIDR string
+	// Since is the snapshot of routes to catch up from
+	Since []Route
+	// Resume enables resuming from the ResumeFrom sequence number
+	Resume bool
+	// ResumeFrom is the sequence number of the last event received before reconnecting
+	ResumeFrom uint64
+	// Priority is the watcher dispatch priority
+	Priority int
+	// LevelTriggered delivers the latest state of each route instead of all the events
+	LevelTriggered bool
+	// Limit is the maximum number of events delivered before the watcher stops
+	Limit int
+	// Map transforms the events before they are queued
+	Map func(*Event) *Event
+	// Delivery is the event delivery mode
+	Delivery Delivery
+	// Resync is the interval of correcting the missed events
+	Resync time.Duration
+	// Sample enables sampling the events
+	Sample bool
+	// SampleFraction is the fraction of the sampled events
+	SampleFraction float64
+	// SampleByMetric biases the sampling towards the low metric routes
+	SampleByMetric bool
+	// VisibilityTimeout is the time after which the events returned by NextAck are redelivered unless acked
+	VisibilityTimeout time.Duration
 }
 
+// Delivery defines how the events are delivered to the watcher
+type Delivery int
+
+const (
+	// DeliveryAsync queues the events in the watcher buffer without waiting for them
+	// to be processed. The events are dropped if the watcher does not keep up.
+	DeliveryAsync Delivery = iota
+	// DeliverySync blocks the dispatch until the watcher acknowledges each event.
+	// The events are never dropped while the watcher is running.
+	DeliverySync
+)
+
 // WatchService sets what service routes to watch
 // Service is the microservice name
 func WatchService(s string) WatchOption {
@@ -78,6 +208,188 @@ func WatchService(s string) WatchOption {
 	}
 }
 
+// WatchRouter sets what router routes to watch
+// Router is the id of the router the routes originate from
+func WatchRouter(id string) WatchOption {
+	return func(o *WatchOptions) {
+		o.Router = id
+	}
+}
+
+// WatchNetwork sets what network routes to watch
+func WatchNetwork(n string) WatchOption {
+	return func(o *WatchOptions) {
+		o.Network = n
+	}
+}
+
+// WatchExpr sets the filter expression the watched routes have to match.
+// Expressions compare the route fields service, address, gateway, network,
+// router and link with quoted strings using ==, !=, startsWith and contains,
+// and metric with numbers using ==, !=, <, <=, > and >=. Comparisons can be
+// combined with && and || and grouped with parentheses, e.g.
+//
+//	metric < 50 && gateway startsWith "10.0."
+func WatchExpr(expr string) WatchOption {
+	return func(o *WatchOptions) {
+		o.Expr = expr
+	}
+}
+
+// WatchAddressCIDR watches the routes whose address IP is in the subnet, e.g. 10.0.1.0/24.
+// Addresses may include a port; the routes with non IP addresses do not match.
+// Watch returns an error if the CIDR is invalid.
+func WatchAddressCIDR(cidr string) WatchOption {
+	return func(o *WatchOptions) {
+		o.AddressCIDR = cidr
+	}
+}
+
+// WatchSince delivers the events reconciling the given snapshot with the current
+// table contents before tailing the live events. An empty snapshot replays the table.
+func WatchSince(snapshot []Route) WatchOption {
+	return func(o *WatchOptions) {
+		if snapshot == nil {
+			snapshot = []Route{}
+		}
+		o.Since = snapshot
+	}
+}
+
+// WatchResumeFrom resumes watching after the event with the given sequence number,
+// e.g. the last event received before the consumer has been disconnected. The watcher
+// first receives the retained events following seq and then the live events. Watch
+// returns ErrSeqTooOld if the events following seq are no longer retained and ErrSeqInFuture
+// if seq has not been emitted by the table, in which cases the consumer has to replay
+// the table, e.g. using WatchSince. WatchResumeFrom
+// takes precedence over WatchSince.
+func WatchResumeFrom(seq uint64) WatchOption {
+	return func(o *WatchOptions) {
+		o.Resume = true
+		o.ResumeFrom = seq
+	}
+}
+
+// WatchPriority sets the watcher dispatch priority.
+// Events are dispatched to higher priority watchers first and when the table
+// dispatch workers fall behind the events of lower priority watchers which
+// are not keeping up are dropped instead of delaying the dispatch.
+// Priority affects the dispatch order, it does not guarantee the delivery.
+func WatchPriority(p int) WatchOption {
+	return func(o *WatchOptions) {
+		o.Priority = p
+	}
+}
+
+// WatchLevelTriggered makes the watcher deliver the latest state of each watched route
+// instead of all the events. Pending events of a route are collapsed into the latest one,
+// so Next returns the freshest state of the routes in the order they have changed.
+// Level triggered watchers have no events channel and they never delay the dispatch.
+func WatchLevelTriggered() WatchOption {
+	return func(o *WatchOptions) {
+		o.LevelTriggered = true
+	}
+}
+
+// WatchLimit stops the watcher once it has delivered n events which have passed the watch filters.
+// The events channel of the watcher is closed once the watcher has stopped, after which Next
+// returns ErrWatcherStopped once all the delivered events have been received.
+func WatchLimit(n int) WatchOption {
+	return func(o *WatchOptions) {
+		o.Limit = n
+	}
+}
+
+// WatchMap sets the function transforming the events before they are queued for the watcher,
+// e.g. to redact or enrich them. The function is given a copy of the event which passed the
+// watch filters, the event metadata must be copied before modifying it. Returning nil drops
+// the event. The function runs on the dispatch path so it must be cheap.
+func WatchMap(fn func(*Event) *Event) WatchOption {
+	return func(o *WatchOptions) {
+		o.Map = fn
+	}
+}
+
+// WatchDelivery sets the event delivery mode of the watcher.
+//
+// Asynchronous delivery, the default, queues the events in the watcher buffer and drops
+// them if the watcher falls behind, so slow watchers never delay the others.
+//
+// Synchronous delivery blocks the dispatch until the watcher has acknowledged each event
+// it has received by sending to the channel returned by its Ack method, e.g.
+//
+//	w, _ := table.Watch(WatchDelivery(DeliverySync))
+//	ack := w.(SyncWatcher).Ack()
+//	for {
+//		event, err := w.Next()
+//		...
+//		ack <- struct{}{}
+//	}
+//
+// Each event must be acknowledged exactly once. Until then no further events are
+// dispatched to any watcher handled by the same dispatcher, including the lower
+// priority ones, so a stalled synchronous watcher stalls the table events. In return
+// the watcher receives every event in the order it has been dispatched. The order of
+// the events of different routes is only preserved with a single dispatch worker,
+// see DispatchWorkers; without dispatch workers the events are dispatched concurrently
+// and may be received in any order. Level triggered watchers ignore the delivery mode.
+func WatchDelivery(d Delivery) WatchOption {
+	return func(o *WatchOptions) {
+		o.Delivery = d
+	}
+}
+
+// WatchWithResync makes the watcher periodically correct the events it has missed, e.g. the events
+// dropped because the watcher has not kept up. The watcher keeps the view of the watched routes
+// built from the events it has queued, starting from the WatchSince snapshot or from the table
+// contents when the watcher has been created, in which case the events emitted before are not
+// delivered. Every interval the view is compared with the table
+// and the watcher receives the events converging the view to the table, with the cause CauseResync.
+//
+// The view holds a copy of every watched route and each resync lists the whole table while
+// holding the table read lock, delaying the table updates, so the interval should be long
+// enough for the table size, e.g. tens of seconds. Watchers with WatchMap ignore the option.
+func WatchWithResync(interval time.Duration) WatchOption {
+	return func(o *WatchOptions) {
+		o.Resync = interval
+	}
+}
+
+// WatchSample delivers a random sample of the live events, approximately the given fraction
+// of them, e.g. to observe a busy table cheaply. The fraction must be greater than 0 and
+// at most 1, which delivers all the events, otherwise Watch returns ErrInvalidSampleFraction.
+// When weighted by metric the events of the low metric routes, which are the preferred ones,
+// are more likely sampled while keeping the overall fraction of the sampled events.
+func WatchSample(fraction float64, weightByMetric bool) WatchOption {
+	return func(o *WatchOptions) {
+		o.Sample = true
+		o.SampleFraction = fraction
+		o.SampleByMetric = weightByMetric
+	}
+}
+
+// WatchVisibilityTimeout sets the time after which the events returned by NextAck
+// are redelivered unless they have been acknowledged, see AckWatcher.
+func WatchVisibilityTimeout(d time.Duration) WatchOption {
+	return func(o *WatchOptions) {
+		o.VisibilityTimeout = d
+	}
+}
+
+// AckWatcher is a watcher redelivering the events which have not been acknowledged
+type AckWatcher interface {
+	Watcher
+	// NextAck returns the next event and the function acknowledging it
+	NextAck() (*Event, func(), error)
+}
+
+// SyncWatcher is a watcher acknowledging the events delivered synchronously
+type SyncWatcher interface {
+	Watcher
+	// Ack returns the channel acknowledging the received events
+	Ack() chan<- struct{}
+}
+
 // tableWatcher implements routing table Watcher
 type tableWatcher struct {
 	sync.RWMutex
@@ -85,31 +397,402 @@ type tableWatcher struct {
 	opts    WatchOptions
 	resChan chan *Event
 	done    chan struct{}
+	stats   *tableStats
+	// resolve resolves service aliases
+	resolve func(string) string
+	// criteria are the parsed watch filters
+	criteria watchCriteria
+	// registered is set once the watcher is registered with the table
+	registered bool
+	// hash returns the route hash
+	hash func(Route) uint64
+	// latest stores the latest events of level triggered watcher routes
+	latest map[uint64]*Event
+	// order stores the routes with pending events in the order they changed
+	order []uint64
+	// notify signals new pending events of level triggered watcher
+	notify chan struct{}
+	// delivered is the number of events delivered to limited watcher
+	delivered int
+	// from is the sequence number of the last event replayed to the watcher
+	from uint64
+	// smu serialises the synchronous deliveries
+	smu sync.Mutex
+	// acks receives the acknowledgements of synchronous watcher
+	acks chan struct{}
+	// unacked is the number of events waiting for acknowledgement
+	unacked int
+	// view stores the routes of the resynced watcher as seen by the consumer
+	view map[uint64]Route
+	// position is the sequence number of the last event returned by the watcher
+	position uint64
+	// sampler samples the events of sampling watcher
+	sampler *sampler
+	// inflight stores the events returned by NextAck which have not been acknowledged
+	inflight map[uint64]*delivery
+	// deliveries is the number of the events returned by NextAck
+	deliveries uint64
+	// seen is the timestamp of the newest event returned by the watcher
+	seen time.Time
+	// emitted returns the timestamp of the last event emitted by the table
+	emitted func() time.Time
+}
+
+// WatcherInfo describes a table watcher
+type WatcherInfo struct {
+	// Id is the watcher id
+	Id string
+	// Service is the watched service
+	Service string
+	// Router is the watched router
+	Router string
+	// Network is the watched network
+	Network string
+	// Expr is the watcher filter expression
+	Expr string
+	// Priority is the watcher dispatch priority
+	Priority int
+}
+
+// info returns the watcher info
+func (w *tableWatcher) info() WatcherInfo {
+	w.RLock()
+	defer w.RUnlock()
+
+	return WatcherInfo{
+		Id:       w.id,
+		Service:  w.opts.Service,
+		Router:   w.opts.Router,
+		Network:  w.opts.Network,
+		Expr:     w.opts.Expr,
+		Priority: w.opts.Priority,
+	}
+}
+
+// match checks if the event matches all the watch options
+func (w *tableWatcher) match(e *Event) bool {
+	w.RLock()
+	defer w.RUnlock()
+
+	if w.opts.Service != "*" && w.resolve(w.opts.Service) != e.Route.Service {
+		return false
+	}
+
+	if w.opts.Router != "*" && w.opts.Router != e.Route.Router {
+		return false
+	}
+
+	if w.opts.Network != "*" && w.opts.Network != e.Route.Network {
+		return false
+	}
+
+	if w.criteria.expr != nil && !w.criteria.expr.eval(e.Route) {
+		return false
+	}
+
+	if w.criteria.cidr != nil && !matchCIDR(w.criteria.cidr, e.Route.Address) {
+		return false
+	}
+
+	return true
+}
+
+// filter checks if the event matches the watch options unless it has been
+// checked already before transforming it
+func (w *tableWatcher) filter(e *Event) bool {
+	if w.opts.Map != nil {
+		return true
+	}
+	return w.match(e)
+}
+
+// transform filters the event and applies the watcher transform to its copy.
+// It returns nil if the event has been filtered out or dropped by the transform.
+func (w *tableWatcher) transform(e *Event) *Event {
+	if w.opts.Map == nil {
+		return e
+	}
+
+	if !w.match(e) {
+		w.stats.incFiltered()
+		return nil
+	}
+
+	c := *e
+	return w.opts.Map(&c)
+}
+
+// send queues the event. It must be called with the watchers lock held.
+func (w *tableWatcher) send(e *Event) {
+	if e = w.transform(e); e == nil {
+		return
+	}
+
+	if w.opts.LevelTriggered {
+		w.collapse(e)
+		return
+	}
+
+	if w.opts.Limit > 0 {
+		ok, last := w.reserve(e)
+		if !ok {
+			return
+		}
+		if last {
+			defer w.Stop()
+		}
+	}
+
+	if w.acks != nil {
+		if !w.filter(e) {
+			w.stats.incFiltered()
+			return
+		}
+		w.smu.Lock()
+		w.unacked++
+		w.smu.Unlock()
+	}
+
+	w.record(e)
+	w.resChan <- e
+}
+
+// await blocks until synchronous watcher has acknowledged all the events it has been sent,
+// the watcher has been stopped or exit is closed. It must be called with smu held.
+func (w *tableWatcher) await(exit <-chan bool) {
+	for w.unacked > 0 {
+		select {
+		case <-w.acks:
+			w.unacked--
+		case <-w.done:
+			return
+		case <-exit:
+			return
+		}
+	}
+}
+
+// Ack returns the channel acknowledging the events received by synchronous watcher.
+// It returns nil if the watcher is not synchronous.
+func (w *tableWatcher) Ack() chan<- struct{} {
+	return w.acks
+}
+
+// seed sets the view of resynced watcher to the watched routes
+func (w *tableWatcher) seed(routes []Route) {
+	for _, route := range routes {
+		if w.match(&Event{Route: route}) {
+			w.view[w.hash(route)] = route
+		}
+	}
+}
+
+// record applies the event queued for resynced watcher to its view
+func (w *tableWatcher) record(e *Event) {
+	if w.view == nil || !w.filter(e) {
+		return
+	}
+
+	key := w.hash(e.Route)
+
+	w.Lock()
+	defer w.Unlock()
+
+	if e.Type == Delete {
+		delete(w.view, key)
+		return
+	}
+	w.view[key] = e.Route
+}
+
+// reserve counts the event delivered to limited watcher. It returns false if the event
+// is filtered out or the limit has been reached and true as last for the last event.
+func (w *tableWatcher) reserve(e *Event) (ok, last bool) {
+	if !w.filter(e) {
+		w.stats.incFiltered()
+		return false, false
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	if w.delivered >= w.opts.Limit {
+		return false, false
+	}
+	w.delivered++
+
+	return true, w.delivered == w.opts.Limit
+}
+
+// collapse replaces the pending event of the event route with the event unless the pending one is newer
+func (w *tableWatcher) collapse(e *Event) {
+	if !w.filter(e) {
+		w.stats.incFiltered()
+		return
+	}
+
+	w.Lock()
+	key := w.hash(e.Route)
+	prev, ok := w.latest[key]
+	if !ok {
+		w.order = append(w.order, key)
+	}
+	// the events may be dispatched out of order
+	if !ok || prev.Seq < e.Seq {
+		w.latest[key] = e
+	}
+	w.Unlock()
+
+	w.record(e)
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// nextLatest returns the latest state of the route which has changed first
+func (w *tableWatcher) nextLatest() (*Event, error) {
+	for {
+		select {
+		case <-w.done:
+			return nil, ErrWatcherStopped
+		default:
+		}
+
+		w.Lock()
+		if len(w.order) > 0 {
+			key := w.order[0]
+			w.order = w.order[1:]
+			e := w.latest[key]
+			delete(w.latest, key)
+			w.advanceLocked(e)
+			w.Unlock()
+			return e, nil
+		}
+		w.Unlock()
+
+		select {
+		case <-w.notify:
+		case <-w.done:
+			return nil, ErrWatcherStopped
+		}
+	}
 }
 
 // Next returns the next noticed action taken on table
-// TODO: right now we only allow to watch particular service
 func (w *tableWatcher) Next() (*Event, error) {
+	if w.opts.LevelTriggered {
+		return w.nextLatest()
+	}
+
 	for {
 		select {
-		case res := <-w.resChan:
-			switch w.opts.Service {
-			case res.Route.Service, "*":
-				return res, nil
-			default:
+		case res, ok := <-w.resChan:
+			// the channel is closed when the table is closed
+			if !ok {
+				return nil, ErrWatcherStopped
+			}
+			if !w.filter(res) {
+				w.stats.incFiltered()
+				// acknowledge the events filtered out after they have been delivered
+				if w.acks != nil {
+					w.acks <- struct{}{}
+				}
 				continue
 			}
+			w.advance(res)
+			return res, nil
 		case <-w.done:
+			// return the events delivered to limited watcher before it stopped
+			if w.opts.Limit > 0 {
+				select {
+				case res, ok := <-w.resChan:
+					if ok {
+						w.advance(res)
+						return res, nil
+					}
+				default:
+				}
+			}
 			return nil, ErrWatcherStopped
 		}
 	}
 }
 
-// Chan returns watcher events channel
+// Chan returns watcher events channel.
+// Level triggered watchers have no events channel.
 func (w *tableWatcher) Chan() (<-chan *Event, error) {
+	if w.opts.LevelTriggered {
+		return nil, ErrLevelTriggered
+	}
 	return w.resChan, nil
 }
 
+// Len returns the number of events currently queued in the watcher.
+// It is a momentary snapshot and is inherently racy: the value may
+// change as soon as it has been read.
+func (w *tableWatcher) Len() int {
+	if w.opts.LevelTriggered {
+		w.RLock()
+		defer w.RUnlock()
+		return len(w.order)
+	}
+	return len(w.resChan)
+}
+
+// UpdateFilter changes the service, router, network, filter expression and address CIDR the watcher watches.
+// The other watch options can not be changed. Events which no longer match are not returned
+// by Next, including the already queued ones, and the pending events of level triggered
+// watcher are filtered again. The events already transformed by WatchMap are not filtered again.
+func (w *tableWatcher) UpdateFilter(opts ...WatchOption) error {
+	w.RLock()
+	wopts := WatchOptions{
+		Service:     w.opts.Service,
+		Router:      w.opts.Router,
+		Network:     w.opts.Network,
+		Expr:        w.opts.Expr,
+		AddressCIDR: w.opts.AddressCIDR,
+	}
+	w.RUnlock()
+
+	for _, o := range opts {
+		o(&wopts)
+	}
+
+	criteria, err := parseCriteria(wopts)
+	if err != nil {
+		return err
+	}
+
+	w.Lock()
+	w.opts.Service = wopts.Service
+	w.opts.Router = wopts.Router
+	w.opts.Network = wopts.Network
+	w.opts.Expr = wopts.Expr
+	w.opts.AddressCIDR = wopts.AddressCIDR
+	w.criteria = criteria
+	w.Unlock()
+
+	if !w.opts.LevelTriggered {
+		return nil
+	}
+
+	// filter the pending events again
+	w.Lock()
+	pending := w.latest
+	order := w.order
+	w.latest = make(map[uint64]*Event)
+	w.order = nil
+	w.Unlock()
+
+	for _, key := range order {
+		w.collapse(pending[key])
+	}
+
+	return nil
+}
+
 // Stop stops routing table watcher
 func (w *tableWatcher) Stop() {
 	w.Lock()
@@ -122,3 +805,57 @@ func (w *tableWatcher) Stop() {
 		close(w.done)
 	}
 }
+
+// WatchableTable is a routing table which can be watched, e.g. *MemoryTable
+type WatchableTable interface {
+	Table
+	// Watch returns a watcher which tracks updates to the routing table
+	Watch(opts ...WatchOption) (Watcher, error)
+}
+
+// WaitForRoute blocks until a route to the given service exists in the table and returns it.
+// It returns immediately if the route already exists, otherwise it waits for the route to be
+// created or updated until the context is cancelled.
+func WaitForRoute(ctx context.Context, t WatchableTable, service string) (Route, error) {
+	// watch before looking up so we don't miss the route being created in between
+	w, err := t.Watch(WatchService(service))
+	if err != nil {
+		return Route{}, err
+	}
+	defer w.Stop()
+
+	routes, err := t.Query(QueryService(service))
+	if err != nil && err != ErrRouteNotFound {
+		return Route{}, err
+	}
+
+	if len(routes) > 0 {
+		return routes[0], nil
+	}
+
+	exit := make(chan bool)
+	defer close(exit)
+
+	// stop the watcher when the context is done to unblock Next
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.Stop()
+		case <-exit:
+		}
+	}()
+
+	for {
+		event, err := w.Next()
+		if err != nil {
+			if ctx.Err() != nil {
+				return Route{}, ctx.Err()
+			}
+			return Route{}, err
+		}
+
+		if event.Type == Create || event.Type == Update {
+			return event.Route, nil
+		}
+	}
+}
@@ -0,0 +1,677 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestWatcherLen(t *testing.T) {
+	table, route := testSetup()
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	if l := w.Len(); l != 0 {
+		t.Errorf("incorrect number of buffered events. Expected: %d, found: %d", 0, l)
+	}
+
+	nrRoutes := 3
+	for i := 0; i < nrRoutes; i++ {
+		route.Address = fmt.Sprintf("dest.addr-%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	// events are dispatched asynchronously
+	deadline := time.Now().Add(time.Second)
+	for w.Len() < nrRoutes && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if l := w.Len(); l != nrRoutes {
+		t.Errorf("incorrect number of buffered events. Expected: %d, found: %d", nrRoutes, l)
+	}
+
+	if _, err := w.Next(); err != nil {
+		t.Fatalf("error receiving event: %s", err)
+	}
+
+	if l := w.Len(); l != nrRoutes-1 {
+		t.Errorf("incorrect number of buffered events. Expected: %d, found: %d", nrRoutes-1, l)
+	}
+}
+
+func TestWaitForRoute(t *testing.T) {
+	table, route := testSetup()
+	defer table.Close()
+
+	// route is created after a delay
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		table.Create(route)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	found, err := WaitForRoute(ctx, table, route.Service)
+	if err != nil {
+		t.Fatalf("error waiting for route: %s", err)
+	}
+
+	if found.Hash() != route.Hash() {
+		t.Errorf("incorrect route returned: %+v", found)
+	}
+
+	// existing route returns immediately
+	found, err = WaitForRoute(context.Background(), table, route.Service)
+	if err != nil {
+		t.Fatalf("error waiting for route: %s", err)
+	}
+
+	if found.Hash() != route.Hash() {
+		t.Errorf("incorrect route returned: %+v", found)
+	}
+
+	// missing route respects context cancellation
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := WaitForRoute(ctx, table, "missing.svc"); err != context.DeadlineExceeded {
+		t.Errorf("expected error: %v, found: %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestWatchRouter(t *testing.T) {
+	table, route := testSetup()
+
+	w, err := table.Watch(WatchService("svc1"), WatchRouter("rtr1"))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	svc := []string{"svc1", "svc1", "svc2", "svc1"}
+	rtr := []string{"rtr1", "rtr2", "rtr1", "rtr1"}
+
+	for i := 0; i < len(svc); i++ {
+		route.Service = svc[i]
+		route.Router = rtr[i]
+		route.Address = fmt.Sprintf("dest.addr-%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		event, err := w.Next()
+		if err != nil {
+			t.Fatalf("error receiving event: %s", err)
+		}
+		if event.Route.Service != "svc1" || event.Route.Router != "rtr1" {
+			t.Errorf("unexpected event for route %s from router %s", event.Route.Service, event.Route.Router)
+		}
+	}
+
+	// no other events should match the watcher
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		w.Stop()
+	}()
+
+	if event, err := w.Next(); err != ErrWatcherStopped {
+		t.Errorf("unexpected event: %v", event)
+	}
+}
+
+func TestWatchNetwork(t *testing.T) {
+	table, route := testSetup()
+
+	w, err := table.Watch(WatchNetwork("net2"))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	for i, net := range []string{"net1", "net2"} {
+		route.Network = net
+		route.Address = fmt.Sprintf("dest.addr-%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	event, err := w.Next()
+	if err != nil {
+		t.Fatalf("error receiving event: %s", err)
+	}
+
+	if event.Route.Network != "net2" {
+		t.Errorf("incorrect event network. Expected: %s, found: %s", "net2", event.Route.Network)
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		w.Stop()
+	}()
+
+	if event, err := w.Next(); err != ErrWatcherStopped {
+		t.Errorf("unexpected event: %v", event)
+	}
+}
+
+func TestWatchSince(t *testing.T) {
+	table, route := testSetup()
+
+	for i := 0; i < 4; i++ {
+		route.Address = fmt.Sprintf("dest.addr-%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	snapshot, err := table.List()
+	if err != nil {
+		t.Fatalf("error listing routes: %s", err)
+	}
+
+	// the consumer holds a partial snapshot
+	var stale []Route
+	for _, r := range snapshot {
+		if r.Address != "dest.addr-0" {
+			stale = append(stale, r)
+		}
+	}
+
+	// the table changes after the snapshot was taken
+	route.Address = "dest.addr-1"
+	if err := table.Delete(route); err != nil {
+		t.Fatalf("error deleting route: %s", err)
+	}
+
+	w, err := table.Watch(WatchSince(stale))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	expected := map[string]EventType{
+		"dest.addr-0": Create,
+		"dest.addr-1": Delete,
+	}
+
+	for i := 0; i < len(expected); i++ {
+		event, err := w.Next()
+		if err != nil {
+			t.Fatalf("error receiving event: %s", err)
+		}
+		typ, ok := expected[event.Route.Address]
+		if !ok || typ != event.Type {
+			t.Errorf("unexpected catch up event %s for route %s", event.Type, event.Route.Address)
+		}
+	}
+
+	// live events follow the catch up
+	route.Address = "dest.addr-4"
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	for {
+		event, err := w.Next()
+		if err != nil {
+			t.Fatalf("error receiving event: %s", err)
+		}
+		// skip the events dispatched before the watcher was created
+		if event.Seq <= 5 {
+			continue
+		}
+		if event.Type != Create || event.Route.Address != route.Address {
+			t.Errorf("unexpected live event %s for route %s", event.Type, event.Route.Address)
+		}
+		break
+	}
+}
+
+func TestMaxWatchers(t *testing.T) {
+	table := newTable(MaxWatchers(3), MaxServiceWatchers(2))
+
+	var watchers []Watcher
+	for i := 0; i < 2; i++ {
+		w, err := table.Watch(WatchService("foo"))
+		if err != nil {
+			t.Fatalf("error creating watcher: %s", err)
+		}
+		watchers = append(watchers, w)
+	}
+
+	// per service limit
+	if _, err := table.Watch(WatchService("foo")); err != ErrTooManyWatchers {
+		t.Fatalf("expected error: %s, found: %v", ErrTooManyWatchers, err)
+	}
+
+	w, err := table.Watch(WatchService("bar"))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	watchers = append(watchers, w)
+
+	// table limit
+	if _, err := table.Watch(); err != ErrTooManyWatchers {
+		t.Fatalf("expected error: %s, found: %v", ErrTooManyWatchers, err)
+	}
+
+	// stopping a watcher frees a slot
+	watchers[0].Stop()
+
+	w, err = table.Watch(WatchService("foo"))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	w.Stop()
+
+	for _, w := range watchers {
+		w.Stop()
+	}
+}
+
+func TestWatcherHooks(t *testing.T) {
+	added := make(chan WatcherInfo, 10)
+	removed := make(chan WatcherInfo, 10)
+
+	var table *MemoryTable
+	table = newTable(
+		OnWatcherAdded(func(info WatcherInfo) {
+			// the hooks can use the table
+			table.List()
+			added <- info
+		}),
+		OnWatcherRemoved(func(info WatcherInfo) {
+			table.List()
+			removed <- info
+		}),
+		MaxWatchers(1),
+	)
+	defer table.Close()
+
+	w, err := table.Watch(WatchService("foo"), WatchPriority(2))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+
+	select {
+	case info := <-added:
+		if info.Service != "foo" || info.Priority != 2 || len(info.Id) == 0 {
+			t.Errorf("incorrect watcher info: %+v", info)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("added hook not called")
+	}
+
+	// rejected watchers are neither added nor removed
+	if _, err := table.Watch(); err != ErrTooManyWatchers {
+		t.Fatalf("expected error: %s, found: %v", ErrTooManyWatchers, err)
+	}
+
+	w.Stop()
+
+	select {
+	case info := <-removed:
+		if info.Service != "foo" {
+			t.Errorf("incorrect watcher info: %+v", info)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("removed hook not called")
+	}
+
+	select {
+	case info := <-added:
+		t.Errorf("unexpected added hook call: %+v", info)
+	case info := <-removed:
+		t.Errorf("unexpected removed hook call: %+v", info)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWatchLevelTriggered(t *testing.T) {
+	// emit updates on metric changes
+	comparator := func(old, new Route) bool {
+		return old.Hash() == new.Hash() && old.Metric == new.Metric
+	}
+
+	table := newTable(UpdateComparator(comparator))
+	defer table.Close()
+
+	w, err := table.Watch(WatchLevelTriggered())
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	if _, err := w.Chan(); err != ErrLevelTriggered {
+		t.Errorf("expected error: %s, found: %v", ErrLevelTriggered, err)
+	}
+
+	_, route := testSetup()
+	other := route
+	other.Address = "dest.addr2"
+
+	if err := table.Create(route); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	for i := 1; i <= 100; i++ {
+		route.Metric = int64(i)
+		if err := table.Update(route); err != nil {
+			t.Fatalf("error updating route: %s", err)
+		}
+	}
+
+	if err := table.Create(other); err != nil {
+		t.Fatalf("error adding route: %s", err)
+	}
+
+	// wait for the events to be dispatched
+	deadline := time.Now().Add(time.Second)
+	for table.Stats().Delivered < 102 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if n := w.Len(); n != 2 {
+		t.Fatalf("expected 2 pending routes, found: %d", n)
+	}
+
+	latest := make(map[string]*Event)
+	for i := 0; i < 2; i++ {
+		e, err := w.Next()
+		if err != nil {
+			t.Fatalf("error receiving event: %s", err)
+		}
+		latest[e.Route.Address] = e
+	}
+
+	if e := latest[route.Address]; e == nil || e.Route.Metric != 100 {
+		t.Errorf("expected the final route state, found: %v", e)
+	}
+
+	if e := latest[other.Address]; e == nil || e.Type != Create {
+		t.Errorf("expected create of the other route, found: %v", e)
+	}
+
+	w.Stop()
+
+	if _, err := w.Next(); err != ErrWatcherStopped {
+		t.Errorf("expected error: %s, found: %v", ErrWatcherStopped, err)
+	}
+}
+
+func TestWatchLimit(t *testing.T) {
+	table := newTable()
+	defer table.Close()
+
+	w, err := table.Watch(WatchService("foo"), WatchLimit(3))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+
+	_, route := testSetup()
+
+	for i := 0; i < 5; i++ {
+		// filtered events are not counted
+		route.Service = "bar"
+		route.Address = fmt.Sprintf("10.0.0.%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+
+		route.Service = "foo"
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		e, err := w.Next()
+		if err != nil {
+			t.Fatalf("error receiving event %d: %s", i, err)
+		}
+		if e.Route.Service != "foo" {
+			t.Errorf("expected foo route event, found: %s", e)
+		}
+	}
+
+	if _, err := w.Next(); err != ErrWatcherStopped {
+		t.Errorf("expected error: %s, found: %v", ErrWatcherStopped, err)
+	}
+
+	ch, err := w.Chan()
+	if err != nil {
+		t.Fatalf("error getting watcher channel: %s", err)
+	}
+
+	select {
+	case e, ok := <-ch:
+		if ok {
+			t.Errorf("unexpected event: %s", e)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("expected watcher channel to be closed")
+	}
+}
+
+func TestUpdateFilter(t *testing.T) {
+	table := newTable()
+	defer table.Close()
+
+	w, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	_, route := testSetup()
+
+	create := func(service, address string) {
+		route.Service = service
+		route.Address = address
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	create("foo", "10.0.0.1")
+	create("bar", "10.0.0.2")
+
+	// wait for the events to be queued
+	deadline := time.Now().Add(time.Second)
+	for w.Len() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := w.UpdateFilter(WatchExpr("metric >")); err == nil {
+		t.Errorf("expected invalid filter expression error")
+	}
+
+	// narrow the filter to foo routes
+	if err := w.UpdateFilter(WatchService("foo")); err != nil {
+		t.Fatalf("error updating filter: %s", err)
+	}
+
+	create("bar", "10.0.0.3")
+	create("foo", "10.0.0.4")
+
+	for _, address := range []string{"10.0.0.1", "10.0.0.4"} {
+		e, err := w.Next()
+		if err != nil {
+			t.Fatalf("error receiving event: %s", err)
+		}
+		if e.Route.Service != "foo" || e.Route.Address != address {
+			t.Errorf("expected foo route %s event, found: %s", address, e)
+		}
+	}
+}
+
+func TestWatchMap(t *testing.T) {
+	table := newTable()
+	defer table.Close()
+
+	redact := func(e *Event) *Event {
+		// drop the internal routes
+		if e.Route.Metadata["internal"] == "true" {
+			return nil
+		}
+		e.Route.Gateway = ""
+		return e
+	}
+
+	w, err := table.Watch(WatchMap(redact), WatchService("foo"))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	plain, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer plain.Stop()
+
+	_, route := testSetup()
+	route.Gateway = "secret.gw"
+
+	for i, service := range []string{"foo", "bar", "foo"} {
+		route.Service = service
+		route.Address = fmt.Sprintf("10.0.0.%d", i)
+		route.Metadata = map[string]string{"internal": fmt.Sprintf("%t", i == 2)}
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	events := collectEvents(w, 100*time.Millisecond)
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, found: %d", len(events))
+	}
+
+	if e := events[0]; e.Route.Address != "10.0.0.0" || len(e.Route.Gateway) > 0 {
+		t.Errorf("expected redacted foo route event, found: %+v", e.Route)
+	}
+
+	// other watchers are not affected
+	for _, e := range collectEvents(plain, 100*time.Millisecond) {
+		if e.Route.Gateway != "secret.gw" {
+			t.Errorf("expected unredacted event, found: %+v", e.Route)
+		}
+	}
+}
+
+func TestWatchAddressCIDR(t *testing.T) {
+	table := newTable()
+	defer table.Close()
+
+	if _, err := table.Watch(WatchAddressCIDR("10.0.1.0/33")); err == nil {
+		t.Fatalf("expected invalid CIDR error")
+	}
+
+	w, err := table.Watch(WatchAddressCIDR("10.0.1.0/24"))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	_, route := testSetup()
+
+	for _, address := range []string{"10.0.1.5:8080", "10.0.2.5:8080", "dest.addr", "10.0.1.6"} {
+		route.Address = address
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	var addresses []string
+	for i := 0; i < 2; i++ {
+		e, err := w.Next()
+		if err != nil {
+			t.Fatalf("error receiving event: %s", err)
+		}
+		addresses = append(addresses, e.Route.Address)
+	}
+
+	sort.Strings(addresses)
+	if fmt.Sprint(addresses) != "[10.0.1.5:8080 10.0.1.6]" {
+		t.Errorf("expected events of the routes in the subnet, found: %v", addresses)
+	}
+}
+
+func TestWatchDeliverySync(t *testing.T) {
+	table := newTable(DispatchWorkers(1))
+	defer table.Close()
+
+	w, err := table.Watch(WatchDelivery(DeliverySync))
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer w.Stop()
+
+	// the asynchronous watcher is dispatched after the synchronous one
+	async, err := table.Watch()
+	if err != nil {
+		t.Fatalf("error creating watcher: %s", err)
+	}
+	defer async.Stop()
+
+	ack := w.(SyncWatcher).Ack()
+	if ack == nil {
+		t.Fatalf("expected ack channel")
+	}
+	if async.(SyncWatcher).Ack() != nil {
+		t.Errorf("expected no ack channel for asynchronous watcher")
+	}
+
+	_, route := testSetup()
+
+	for i := 0; i < 2; i++ {
+		route.Address = fmt.Sprintf("10.0.0.%d", i)
+		if err := table.Create(route); err != nil {
+			t.Fatalf("error adding route: %s", err)
+		}
+	}
+
+	e, err := w.Next()
+	if err != nil {
+		t.Fatalf("error receiving event: %s", err)
+	}
+	if e.Seq != 1 {
+		t.Fatalf("expected event 1, found: %d", e.Seq)
+	}
+
+	// the dispatch waits for the acknowledgement
+	if events := collectEvents(async, 100*time.Millisecond); len(events) != 0 {
+		t.Errorf("expected no events before acknowledgement, found: %d", len(events))
+	}
+	if n := w.Len(); n != 0 {
+		t.Errorf("expected no queued events before acknowledgement, found: %d", n)
+	}
+
+	ack <- struct{}{}
+
+	e, err = w.Next()
+	if err != nil {
+		t.Fatalf("error receiving event: %s", err)
+	}
+	if e.Seq != 2 {
+		t.Fatalf("expected event 2, found: %d", e.Seq)
+	}
+	ack <- struct{}{}
+
+	if events := collectEvents(async, 100*time.Millisecond); len(events) != 2 {
+		t.Errorf("expected 2 events after acknowledgement, found: %d", len(events))
+	}
+}